@@ -0,0 +1,257 @@
+// Command deviced serves the device inventory API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"homework/internal/adapters/fakerepo"
+	"homework/internal/adapters/filerepo"
+	"homework/internal/adapters/sqlrepo"
+	"homework/internal/app"
+	"homework/internal/auth"
+	"homework/internal/client"
+	"homework/internal/config"
+	"homework/internal/health"
+	"homework/internal/logger"
+	"homework/internal/metrics"
+	"homework/internal/ports/handler"
+	"homework/internal/ports/handler/validate"
+	portsserver "homework/internal/ports/server"
+	"homework/internal/problem"
+	"homework/internal/rbac"
+	"homework/internal/redact"
+	"homework/internal/roundtripper"
+	"homework/internal/server"
+	"homework/internal/tracing"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Exit codes of deviced.
+const (
+	exitOK = 0
+	// exitFailure means the server failed to start or stopped with an error.
+	exitFailure = 1
+	// exitUnclean means in-flight requests were cut off by the shutdown deadline
+	// or the storage failed to flush on close.
+	exitUnclean = 2
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	configPath := flag.String("config", envOr("CONFIG_PATH", "config/local.yaml"), "path to the config file, $CONFIG_PATH")
+	backend := flag.String("storage", "", "storage backend: memory, file or sqlite, overrides storage.backend")
+	storagePath := flag.String("storage-path", "", "data directory or sqlite DSN, overrides storage.path")
+	addr := flag.String("addr", "", "listen address, overrides http_server.address")
+	flag.Parse()
+
+	cfg := config.LoadConfig(*configPath)
+	if *backend != "" {
+		cfg.Storage.Backend = *backend
+	}
+	if *storagePath != "" {
+		cfg.Storage.Path = *storagePath
+	}
+	if *addr != "" {
+		cfg.Address = *addr
+	}
+
+	l, err := logger.New(cfg, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot set up logging: %v\n", err)
+		return exitFailure
+	}
+	slog.SetDefault(l)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("cannot set up tracing", slog.Any("error", err))
+		return exitFailure
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("cannot flush traces", slog.Any("error", err))
+		}
+	}()
+
+	storage, err := newStorage(cfg.Storage)
+	if err != nil {
+		slog.Error("cannot open storage", slog.Any("error", err))
+		return exitFailure
+	}
+
+	validator, err := validate.NewRegistryFromConfig(cfg.Validation)
+	if err != nil {
+		slog.Error("cannot load validation rules", slog.Any("error", err))
+		closeStorage(storage)
+		return exitFailure
+	}
+
+	authenticator, err := auth.NewAuthenticator(cfg.HTTPServer)
+	if err != nil {
+		slog.Error("cannot set up authentication", slog.Any("error", err))
+		closeStorage(storage)
+		return exitFailure
+	}
+	if closer, ok := authenticator.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	authn := server.Authentication{Basic: authenticator, ClientCert: cfg.TLS.ClientCAFile != "", Admins: auth.NewAdmins(cfg.Admins)}
+	opts := []handler.Option{
+		handler.WithLegacyRoutes(cfg.LegacyRoutes),
+		handler.WithValidator(validator),
+	}
+	if cfg.APIKeysFile != "" {
+		apiKeys, err := auth.NewAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			slog.Error("cannot load API keys", slog.Any("error", err))
+			closeStorage(storage)
+			return exitFailure
+		}
+		authn.APIKeys = apiKeys
+		opts = append(opts, handler.WithAPIKeys(apiKeys))
+	}
+	// outgoing requests share the breakers, the readiness check reports them
+	breakers := roundtripper.NewHostBreakers(cfg.HttpClient.Breaker)
+	if cfg.JWT.JWKSFile != "" || cfg.JWT.JWKSURL != "" {
+		verifier, err := auth.NewJWTVerifier(cfg.JWT, client.NewClientWithBreakers(cfg, breakers))
+		if err != nil {
+			slog.Error("cannot set up JWT authentication", slog.Any("error", err))
+			closeStorage(storage)
+			return exitFailure
+		}
+		authn.JWT = verifier
+	}
+
+	var (
+		service     handler.Service = app.NewService(tracing.NewStorage(metrics.NewStorage(storage)))
+		middlewares []func(http.Handler) http.Handler
+	)
+	if cfg.RBAC.Enabled {
+		authorizer, err := rbac.NewAuthorizer(cfg.RBAC)
+		if err != nil {
+			slog.Error("cannot set up access control", slog.Any("error", err))
+			closeStorage(storage)
+			return exitFailure
+		}
+		service = rbac.NewService(service, authorizer)
+		middlewares = append(middlewares, authorizer.Middleware(problem.WriteError))
+	}
+	h := handler.NewHandler(service, opts...)
+
+	srv := new(portsserver.Server)
+	readiness := health.NewRegistry(cfg.Health.Timeout)
+	readiness.Register("server", srv)
+	readiness.Register("storage", health.Storage(storage))
+	if cfg.Storage.Backend == "file" {
+		readiness.Register("disk", health.DiskSpace(cfg.Storage.Path, cfg.Health.MinFreeDiskBytes))
+	}
+	readiness.Register("circuit_breakers", health.Breakers(breakers.Breakers), health.Optional())
+	if reloader, ok := authenticator.(health.Reloader); ok {
+		readiness.Register("htpasswd_reload", health.Reload(reloader), health.Optional())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health.NewRegistry(cfg.Health.Timeout))
+	mux.Handle("/readyz", readiness)
+	metrics.Registry.MustRegister(metrics.NewInventoryCollector(storage))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/", server.Chain(h.InitRoutes(), authn, redact.PolicyFromConfig(cfg.Redaction), middlewares...))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server",
+			slog.String("address", cfg.Address),
+			slog.String("storage", cfg.Storage.Backend),
+			slog.Bool("tls", cfg.TLS.CertFile != ""),
+		)
+		serveErr <- srv.Run(cfg, mux)
+	}()
+
+	code := exitOK
+	select {
+	case err := <-serveErr:
+		slog.Error("server error", slog.Any("error", err))
+		closeStorage(storage)
+		return exitFailure
+	case <-ctx.Done():
+		// a second signal kills the process right away
+		stop()
+	}
+
+	slog.Info("shutting down, draining requests", slog.Duration("timeout", cfg.HTTPServer.ShutdownTimeout))
+	srv.SetReady(false)
+	time.Sleep(cfg.HTTPServer.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("graceful shutdown failed", slog.Any("error", err))
+		code = exitUnclean
+	}
+	// Run returns http.ErrServerClosed if the signal came before it started listening
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server error", slog.Any("error", err))
+		code = exitFailure
+	}
+	if !closeStorage(storage) && code == exitOK {
+		code = exitUnclean
+	}
+	slog.Info("server stopped")
+	return code
+}
+
+// closeStorage flushes and closes storages that hold resources, it reports whether that succeeded.
+func closeStorage(storage app.DeviceStorage) bool {
+	closer, ok := storage.(io.Closer)
+	if !ok {
+		return true
+	}
+	if err := closer.Close(); err != nil {
+		slog.Error("cannot close storage", slog.Any("error", err))
+		return false
+	}
+	return true
+}
+
+func newStorage(cfg config.Storage) (app.DeviceStorage, error) {
+	switch cfg.Backend {
+	case "memory", "":
+		return fakerepo.NewDeviceStorage(), nil
+	case "file":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("file storage requires a data directory")
+		}
+		return filerepo.NewDeviceStorage(cfg.Path, cfg.CompactInterval)
+	case "sqlite":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("sqlite storage requires a DSN")
+		}
+		return sqlrepo.NewDeviceStorage(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
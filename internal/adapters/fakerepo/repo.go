@@ -1,7 +1,8 @@
 package fakerepo
 
 import (
-	"errors"
+	"context"
+	"homework/internal/app"
 	"homework/internal/device"
 	"sync"
 )
@@ -12,8 +13,8 @@ type DeviceStorage struct {
 }
 
 var (
-	ErrNoSuchDevice        = errors.New("there is no such device")
-	ErrDeviceAlreadyExists = errors.New("such device is already in database")
+	ErrNoSuchDevice        = app.NewError(app.ErrNotFound, "device_not_found", "there is no such device")
+	ErrDeviceAlreadyExists = app.NewError(app.ErrConflict, "device_already_exists", "such device is already in database")
 )
 
 func NewDeviceStorage() *DeviceStorage {
@@ -22,7 +23,7 @@ func NewDeviceStorage() *DeviceStorage {
 	}
 }
 
-func (s *DeviceStorage) GetDeviceBySerialNum(serialNum string) (device.Device, error) {
+func (s *DeviceStorage) GetDeviceBySerialNum(ctx context.Context, serialNum string) (device.Device, error) {
 	defer s.Unlock()
 	s.Lock()
 	if val, ok := s.devices[serialNum]; ok {
@@ -31,7 +32,7 @@ func (s *DeviceStorage) GetDeviceBySerialNum(serialNum string) (device.Device, e
 	return device.Device{}, ErrNoSuchDevice
 }
 
-func (s *DeviceStorage) CreateDevice(device device.Device) error {
+func (s *DeviceStorage) CreateDevice(ctx context.Context, device device.Device) error {
 	defer s.Unlock()
 	s.Lock()
 	if _, ok := s.devices[device.SerialNum]; ok {
@@ -41,7 +42,7 @@ func (s *DeviceStorage) CreateDevice(device device.Device) error {
 	return nil
 }
 
-func (s *DeviceStorage) DeleteDeviceBySerialNum(serialNum string) error {
+func (s *DeviceStorage) DeleteDeviceBySerialNum(ctx context.Context, serialNum string) error {
 	defer s.Unlock()
 	s.Lock()
 	if _, ok := s.devices[serialNum]; ok {
@@ -51,7 +52,7 @@ func (s *DeviceStorage) DeleteDeviceBySerialNum(serialNum string) error {
 	return ErrNoSuchDevice
 }
 
-func (s *DeviceStorage) UpdateDevice(device device.Device) error {
+func (s *DeviceStorage) UpdateDevice(ctx context.Context, device device.Device) error {
 	defer s.Unlock()
 	s.Lock()
 	if _, ok := s.devices[device.SerialNum]; ok {
@@ -60,3 +61,23 @@ func (s *DeviceStorage) UpdateDevice(device device.Device) error {
 	}
 	return ErrNoSuchDevice
 }
+
+func (s *DeviceStorage) ListDevices(ctx context.Context, query device.ListQuery) (device.Page, error) {
+	s.Lock()
+	devices := make([]device.Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		devices = append(devices, d)
+	}
+	s.Unlock()
+	return device.Paginate(devices, query)
+}
+
+func (s *DeviceStorage) CountDevicesByModel(ctx context.Context) (map[string]int, error) {
+	s.Lock()
+	defer s.Unlock()
+	counts := make(map[string]int)
+	for _, d := range s.devices {
+		counts[d.Model]++
+	}
+	return counts, nil
+}
@@ -1,9 +1,11 @@
 package fakerepo
 
 import (
+	"context"
 	"github.com/stretchr/testify/suite"
+	"homework/internal/adapters/storagetest"
+	"homework/internal/app"
 	"homework/internal/device"
-	"log"
 	"sync"
 	"testing"
 )
@@ -19,14 +21,11 @@ func TestMyTestSuite(t *testing.T) {
 
 // Create some initial values in our map before tests
 func (s *MyTestSuite) SetupTest() {
-	log.Println("SetupSuite()")
 	s.devices = map[string]device.Device{"1235": {SerialNum: "1235", Model: "HP", IP: "121.121.121.121"},
 		"7112": {SerialNum: "1235", Model: "HP", IP: "121.121.121.121"}}
 }
 
 func (s *MyTestSuite) TearDownSuite() {
-	log.Println("TearDownSuite()")
-
 	// Imitation of deletion of created database ( if it used)
 	s.devices = map[string]device.Device{}
 }
@@ -54,7 +53,7 @@ func (s *MyTestSuite) TestDeviceStorage_GetDeviceBySerialNumDevice() {
 				Mutex:   sync.Mutex{},
 				devices: s.devices,
 			}
-			got, err := storage.GetDeviceBySerialNum(tt.serialNum)
+			got, err := storage.GetDeviceBySerialNum(context.Background(), tt.serialNum)
 			if err != tt.err {
 				s.T().Error("Expected and result error is not equal")
 			}
@@ -88,7 +87,7 @@ func (s *MyTestSuite) TestDeviceStorage_CreateDevice() {
 				Mutex:   sync.Mutex{},
 				devices: s.devices,
 			}
-			err := storage.CreateDevice(tt.device)
+			err := storage.CreateDevice(context.Background(), tt.device)
 			if err != tt.err {
 				s.T().Error("Expected and result error is not equal")
 			}
@@ -119,7 +118,7 @@ func (s *MyTestSuite) TestDeviceStorage_DeleteDeviceBySerialNumDevice() {
 				Mutex:   sync.Mutex{},
 				devices: s.devices,
 			}
-			err := storage.DeleteDeviceBySerialNum(tt.serialNum)
+			err := storage.DeleteDeviceBySerialNum(context.Background(), tt.serialNum)
 			if err != tt.err {
 				s.T().Error("Expected and result error is not equal")
 			}
@@ -150,10 +149,16 @@ func (s *MyTestSuite) TestDeviceStorage_UpdateDevice() {
 				Mutex:   sync.Mutex{},
 				devices: s.devices,
 			}
-			err := storage.UpdateDevice(tt.device)
+			err := storage.UpdateDevice(context.Background(), tt.device)
 			if err != tt.err {
 				s.T().Error("Expected and result error is not equal")
 			}
 		})
 	}
 }
+
+func TestDeviceStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func() app.DeviceStorage {
+		return NewDeviceStorage()
+	})
+}
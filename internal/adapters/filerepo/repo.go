@@ -0,0 +1,429 @@
+package filerepo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"homework/internal/app"
+	"homework/internal/device"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+type operation string
+
+const (
+	opCreate operation = "create"
+	opUpdate operation = "update"
+	opDelete operation = "delete"
+)
+
+var (
+	ErrNoSuchDevice        = app.NewError(app.ErrNotFound, "device_not_found", "there is no such device")
+	ErrDeviceAlreadyExists = app.NewError(app.ErrConflict, "device_already_exists", "such device is already in database")
+	ErrStorageClosed       = app.NewError(app.ErrUnavailable, "storage_closed", "storage is closed")
+	ErrStorageBroken       = app.NewError(app.ErrUnavailable, "storage_broken", "storage log is inconsistent, writes are refused until it is compacted")
+)
+
+// logFile is the part of *os.File the write-ahead log needs.
+type logFile interface {
+	io.WriteSeeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// record is a single line of the write-ahead log.
+type record struct {
+	Op     operation     `json:"op"`
+	Device device.Device `json:"device"`
+}
+
+// DeviceStorage keeps devices in memory and appends every change to a write-ahead log,
+// so the state can be restored after restart. The log is periodically compacted into a snapshot.
+type DeviceStorage struct {
+	sync.Mutex
+	devices map[string]device.Device
+	dir     string
+	wal     logFile
+	// broken is set when a failed append couldn't be rolled back, the log may then
+	// hold a record that wasn't applied, so writes are refused until the next compaction.
+	broken bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewDeviceStorage restores devices from the snapshot and the write-ahead log in dir.
+// If compactInterval is positive, the log is compacted into a snapshot in the background.
+func NewDeviceStorage(dir string, compactInterval time.Duration) (*DeviceStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+	s := &DeviceStorage{
+		devices: make(map[string]device.Device),
+		dir:     dir,
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayWAL(); err != nil {
+		return nil, err
+	}
+
+	if compactInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.compactLoop(compactInterval, s.stop, s.done)
+	}
+	return s, nil
+}
+
+func (s *DeviceStorage) GetDeviceBySerialNum(ctx context.Context, serialNum string) (device.Device, error) {
+	defer s.Unlock()
+	s.Lock()
+	if val, ok := s.devices[serialNum]; ok {
+		return val, nil
+	}
+	return device.Device{}, ErrNoSuchDevice
+}
+
+func (s *DeviceStorage) CreateDevice(ctx context.Context, device device.Device) error {
+	defer s.Unlock()
+	s.Lock()
+	if _, ok := s.devices[device.SerialNum]; ok {
+		return ErrDeviceAlreadyExists
+	}
+	if err := s.appendRecord(record{Op: opCreate, Device: device}); err != nil {
+		return err
+	}
+	s.devices[device.SerialNum] = device
+	return nil
+}
+
+func (s *DeviceStorage) DeleteDeviceBySerialNum(ctx context.Context, serialNum string) error {
+	defer s.Unlock()
+	s.Lock()
+	d, ok := s.devices[serialNum]
+	if !ok {
+		return ErrNoSuchDevice
+	}
+	if err := s.appendRecord(record{Op: opDelete, Device: d}); err != nil {
+		return err
+	}
+	delete(s.devices, serialNum)
+	return nil
+}
+
+func (s *DeviceStorage) UpdateDevice(ctx context.Context, device device.Device) error {
+	defer s.Unlock()
+	s.Lock()
+	if _, ok := s.devices[device.SerialNum]; !ok {
+		return ErrNoSuchDevice
+	}
+	if err := s.appendRecord(record{Op: opUpdate, Device: device}); err != nil {
+		return err
+	}
+	s.devices[device.SerialNum] = device
+	return nil
+}
+
+func (s *DeviceStorage) ListDevices(ctx context.Context, query device.ListQuery) (device.Page, error) {
+	s.Lock()
+	devices := make([]device.Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		devices = append(devices, d)
+	}
+	s.Unlock()
+	return device.Paginate(devices, query)
+}
+
+func (s *DeviceStorage) CountDevicesByModel(ctx context.Context) (map[string]int, error) {
+	s.Lock()
+	defer s.Unlock()
+	counts := make(map[string]int)
+	for _, d := range s.devices {
+		counts[d.Model]++
+	}
+	return counts, nil
+}
+
+// Compact writes the current state into a snapshot and truncates the write-ahead log.
+func (s *DeviceStorage) Compact() error {
+	defer s.Unlock()
+	s.Lock()
+	return s.compact()
+}
+
+// Close stops background compaction, compacts the log one last time and closes it.
+func (s *DeviceStorage) Close() error {
+	s.Lock()
+	stop, done := s.stop, s.done
+	s.stop = nil
+	s.Unlock()
+	// the lock isn't held while waiting, the last compaction of the loop needs it
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	defer s.Unlock()
+	s.Lock()
+	if s.wal == nil {
+		return ErrStorageClosed
+	}
+	err := s.compact()
+	if closeErr := s.wal.Close(); err == nil {
+		err = closeErr
+	}
+	s.wal = nil
+	return err
+}
+
+func (s *DeviceStorage) compactLoop(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.Compact(); err != nil {
+				slog.Error("failed to compact device log", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// appendRecord writes the record to the log and syncs it to disk.
+// Each line is prefixed with the CRC32 of its payload to detect torn writes.
+// A failed write or sync is rolled back, so the log never holds a torn line
+// followed by acknowledged records, nor a record of a change reported as failed.
+func (s *DeviceStorage) appendRecord(rec record) error {
+	if s.wal == nil {
+		return ErrStorageClosed
+	}
+	if s.broken {
+		return ErrStorageBroken
+	}
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode log record: %w", err)
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)
+
+	offset, err := s.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to get log offset", err)
+	}
+	if _, err := io.WriteString(s.wal, line); err != nil {
+		s.rollback(offset)
+		return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to write log record", err)
+	}
+	if err := s.wal.Sync(); err != nil {
+		s.rollback(offset)
+		return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to sync log", err)
+	}
+	return nil
+}
+
+// rollback cuts the log back to offset after a failed append and marks the storage
+// broken if that fails too.
+func (s *DeviceStorage) rollback(offset int64) {
+	err := s.wal.Truncate(offset)
+	if err == nil {
+		_, err = s.wal.Seek(offset, io.SeekStart)
+	}
+	if err == nil {
+		err = s.wal.Sync()
+	}
+	if err != nil {
+		s.broken = true
+		slog.Error("failed to roll back device log, refusing writes until compaction", slog.Any("error", err))
+	}
+}
+
+func (s *DeviceStorage) compact() error {
+	if s.wal == nil {
+		return ErrStorageClosed
+	}
+	devices := make([]device.Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		devices = append(devices, d)
+	}
+	data, err := json.Marshal(devices)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFileName), data); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	// Replaying the log over the snapshot is idempotent, so a crash
+	// between the rename above and the truncate below loses nothing.
+	if err := s.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncate log: %w", err)
+	}
+	if _, err := s.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewind log: %w", err)
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	// the snapshot holds exactly the applied changes, so an inconsistent log is gone
+	s.broken = false
+	return nil
+}
+
+func (s *DeviceStorage) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	var devices []device.Device
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	for _, d := range devices {
+		s.devices[d.SerialNum] = d
+	}
+	return nil
+}
+
+// replayWAL applies the log on top of the snapshot and opens it for appending.
+// A corrupted tail left by a crash in the middle of a write is cut off. A corrupted record
+// cuts off the records after it as well, they are counted and logged as an error.
+func (s *DeviceStorage) replayWAL() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+
+	var valid int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if len(line) > 0 {
+				slog.Warn("discarding incomplete device log record", slog.Int64("offset", valid))
+			}
+			break
+		}
+		rec, ok := decodeRecord(line)
+		if !ok {
+			records, size, err := countRest(reader)
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("read log: %w", err)
+			}
+			slog.Error("discarding corrupted device log records",
+				slog.Int64("offset", valid),
+				slog.Int("dropped_records", records+1),
+				slog.Int64("dropped_bytes", size+int64(len(line))))
+			break
+		}
+		s.apply(rec)
+		valid += int64(len(line))
+	}
+
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return fmt.Errorf("truncate log: %w", err)
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return fmt.Errorf("seek log: %w", err)
+	}
+	s.wal = f
+	return nil
+}
+
+// countRest counts the lines and bytes left in r, an incomplete last line counts too.
+func countRest(r *bufio.Reader) (lines int, n int64, err error) {
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			lines++
+			n += int64(len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			return lines, n, nil
+		}
+		if err != nil {
+			return lines, n, err
+		}
+	}
+}
+
+func (s *DeviceStorage) apply(rec record) {
+	switch rec.Op {
+	case opCreate, opUpdate:
+		s.devices[rec.Device.SerialNum] = rec.Device
+	case opDelete:
+		delete(s.devices, rec.Device.SerialNum)
+	}
+}
+
+func decodeRecord(line []byte) (record, bool) {
+	var rec record
+	checksum, payload, found := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !found {
+		return rec, false
+	}
+	var want uint32
+	if _, err := fmt.Sscanf(string(checksum), "%08x", &want); err != nil || crc32.ChecksumIEEE(payload) != want {
+		return rec, false
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, false
+	}
+	return rec, true
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path,
+// syncing both the file and its directory.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
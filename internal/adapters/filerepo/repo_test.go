@@ -0,0 +1,279 @@
+package filerepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/adapters/storagetest"
+	"homework/internal/app"
+	"homework/internal/device"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeviceStorage_CRUD(t *testing.T) {
+	storage, err := NewDeviceStorage(t.TempDir(), 0)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	d := device.Device{SerialNum: "1235", Model: "HP", IP: "121.121.121.121"}
+
+	require.NoError(t, storage.CreateDevice(context.Background(), d))
+	assert.Equal(t, ErrDeviceAlreadyExists, storage.CreateDevice(context.Background(), d))
+
+	got, err := storage.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	require.NoError(t, err)
+	assert.Equal(t, d, got)
+
+	d.Model = "ASUS"
+	require.NoError(t, storage.UpdateDevice(context.Background(), d))
+	assert.Equal(t, ErrNoSuchDevice, storage.UpdateDevice(context.Background(), device.Device{SerialNum: "4444"}))
+
+	require.NoError(t, storage.DeleteDeviceBySerialNum(context.Background(), d.SerialNum))
+	assert.Equal(t, ErrNoSuchDevice, storage.DeleteDeviceBySerialNum(context.Background(), d.SerialNum))
+
+	_, err = storage.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	assert.Equal(t, ErrNoSuchDevice, err)
+}
+
+func TestDeviceStorage_ReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "1111", Model: "HP", IP: "1.1.1.1"}))
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "2222", Model: "HP", IP: "1.1.1.2"}))
+	require.NoError(t, storage.UpdateDevice(context.Background(), device.Device{SerialNum: "1111", Model: "ASUS", IP: "1.1.1.1"}))
+	require.NoError(t, storage.DeleteDeviceBySerialNum(context.Background(), "2222"))
+
+	// simulate crash: reopen without Close, so only the log is on disk
+	restored, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	got, err := restored.GetDeviceBySerialNum(context.Background(), "1111")
+	require.NoError(t, err)
+	assert.Equal(t, "ASUS", got.Model)
+
+	_, err = restored.GetDeviceBySerialNum(context.Background(), "2222")
+	assert.Equal(t, ErrNoSuchDevice, err)
+}
+
+func TestDeviceStorage_Compact(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "1111", Model: "HP", IP: "1.1.1.1"}))
+	require.NoError(t, storage.Compact())
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "2222", Model: "HP", IP: "1.1.1.2"}))
+	require.NoError(t, storage.Close())
+
+	restored, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	for _, serialNum := range []string{"1111", "2222"} {
+		_, err := restored.GetDeviceBySerialNum(context.Background(), serialNum)
+		assert.NoError(t, err)
+	}
+}
+
+func TestDeviceStorage_BackgroundCompaction(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewDeviceStorage(dir, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "1111", Model: "HP", IP: "1.1.1.1"}))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot was not written by background compaction")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeviceStorage_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "1111", Model: "HP", IP: "1.1.1.1"}))
+
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`0000beef {"op":"create","dev`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restored, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+
+	_, err = restored.GetDeviceBySerialNum(context.Background(), "1111")
+	assert.NoError(t, err)
+
+	// the torn record is cut off and new writes are appended after the valid ones
+	require.NoError(t, restored.CreateDevice(context.Background(), device.Device{SerialNum: "2222", Model: "HP", IP: "1.1.1.2"}))
+	require.NoError(t, restored.wal.Close())
+	restored.wal = nil
+
+	reopened, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+	defer reopened.Close()
+	_, err = reopened.GetDeviceBySerialNum(context.Background(), "2222")
+	assert.NoError(t, err)
+}
+
+func TestDeviceStorage_CorruptedRecord(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewDeviceStorage(dir, 0)
+	require.NoError(t, err)
+	for _, serialNum := range []string{"1111", "2222", "3333"} {
+		require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: serialNum, Model: "HP", IP: "1.1.1.1"}))
+	}
+	require.NoError(t, storage.wal.Close())
+	storage.wal = nil
+
+	// flip a byte in the payload of the second record
+	path := filepath.Join(dir, walFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	second := bytes.Index(data, lines[1])
+	data[second+len(lines[1])-3] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	restored, err := NewDeviceStorage(dir, 0)
+	slog.SetDefault(defaultLogger)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	_, err = restored.GetDeviceBySerialNum(context.Background(), "1111")
+	assert.NoError(t, err)
+	for _, serialNum := range []string{"2222", "3333"} {
+		_, err = restored.GetDeviceBySerialNum(context.Background(), serialNum)
+		assert.ErrorIs(t, err, ErrNoSuchDevice)
+	}
+
+	var line struct {
+		Level          string `json:"level"`
+		Offset         int    `json:"offset"`
+		DroppedRecords int    `json:"dropped_records"`
+		DroppedBytes   int    `json:"dropped_bytes"`
+	}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "ERROR", line.Level)
+	assert.Equal(t, len(lines[0]), line.Offset)
+	assert.Equal(t, 2, line.DroppedRecords)
+	assert.Equal(t, len(lines[1])+len(lines[2]), line.DroppedBytes)
+}
+
+// faultyLog fails writes after writing half of the bytes, the next sync and truncates as configured.
+type faultyLog struct {
+	logFile
+	failWrite, failSync, failTruncate bool
+}
+
+var errInjected = errors.New("injected I/O error")
+
+func (f *faultyLog) Write(p []byte) (int, error) {
+	if f.failWrite {
+		n, _ := f.logFile.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	return f.logFile.Write(p)
+}
+
+func (f *faultyLog) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errInjected
+	}
+	return f.logFile.Sync()
+}
+
+func (f *faultyLog) Truncate(size int64) error {
+	if f.failTruncate {
+		return errInjected
+	}
+	return f.logFile.Truncate(size)
+}
+
+func TestDeviceStorage_FailedAppend(t *testing.T) {
+	tests := []struct {
+		name       string
+		log        faultyLog
+		wantBroken bool
+	}{
+		{name: "failed write", log: faultyLog{failWrite: true}},
+		{name: "failed sync", log: faultyLog{failSync: true}},
+		{name: "failed rollback", log: faultyLog{failWrite: true, failTruncate: true}, wantBroken: true},
+		{name: "failed sync and rollback", log: faultyLog{failSync: true, failTruncate: true}, wantBroken: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			storage, err := NewDeviceStorage(dir, 0)
+			require.NoError(t, err)
+			require.NoError(t, storage.CreateDevice(ctx, device.Device{SerialNum: "1111", Model: "HP", IP: "1.1.1.1"}))
+
+			faulty := tt.log
+			faulty.logFile = storage.wal
+			storage.wal = &faulty
+			err = storage.CreateDevice(ctx, device.Device{SerialNum: "2222", Model: "HP", IP: "1.1.1.2"})
+			assert.ErrorIs(t, err, app.ErrUnavailable)
+			_, err = storage.GetDeviceBySerialNum(ctx, "2222")
+			assert.ErrorIs(t, err, app.ErrNotFound, "a failed write must not be applied")
+
+			storage.wal = faulty.logFile
+			err = storage.CreateDevice(ctx, device.Device{SerialNum: "3333", Model: "HP", IP: "1.1.1.3"})
+			if tt.wantBroken {
+				assert.ErrorIs(t, err, ErrStorageBroken)
+				require.NoError(t, storage.Compact())
+				require.NoError(t, storage.CreateDevice(ctx, device.Device{SerialNum: "3333", Model: "HP", IP: "1.1.1.3"}))
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, storage.wal.Close())
+			storage.wal = nil
+
+			restored, err := NewDeviceStorage(dir, 0)
+			require.NoError(t, err)
+			defer restored.Close()
+			_, err = restored.GetDeviceBySerialNum(ctx, "1111")
+			assert.NoError(t, err)
+			_, err = restored.GetDeviceBySerialNum(ctx, "2222")
+			assert.ErrorIs(t, err, app.ErrNotFound, "the failed write must not be replayed")
+			_, err = restored.GetDeviceBySerialNum(ctx, "3333")
+			assert.NoError(t, err, "writes acknowledged after the failed one must be replayed")
+		})
+	}
+}
+
+func TestDeviceStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func() app.DeviceStorage {
+		storage, err := NewDeviceStorage(t.TempDir(), 0)
+		require.NoError(t, err)
+		return storage
+	})
+}
@@ -0,0 +1,7 @@
+CREATE TABLE devices (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    serial_num TEXT    NOT NULL,
+    model      TEXT    NOT NULL,
+    ip         TEXT    NOT NULL,
+    CONSTRAINT devices_serial_num_unique UNIQUE (serial_num)
+);
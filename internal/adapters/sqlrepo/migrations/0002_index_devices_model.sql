@@ -0,0 +1 @@
+CREATE INDEX idx_devices_model ON devices (model);
@@ -0,0 +1,307 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"homework/internal/app"
+	"homework/internal/device"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var (
+	ErrNoSuchDevice        = app.NewError(app.ErrNotFound, "device_not_found", "there is no such device")
+	ErrDeviceAlreadyExists = app.NewError(app.ErrConflict, "device_already_exists", "such device is already in database")
+)
+
+// DeviceStorage stores devices in an embedded SQLite database.
+type DeviceStorage struct {
+	db *sql.DB
+}
+
+// NewDeviceStorage opens the SQLite database at dsn and applies pending migrations.
+// Use ":memory:" or "file::memory:?cache=shared" for an in-memory database.
+func NewDeviceStorage(dsn string) (*DeviceStorage, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	// sqlite allows only one writer, so a single connection avoids "database is locked"
+	// errors and keeps in-memory databases from being opened once per connection.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DeviceStorage{db: db}, nil
+}
+
+func (s *DeviceStorage) GetDeviceBySerialNum(ctx context.Context, serialNum string) (device.Device, error) {
+	var d device.Device
+	err := s.db.QueryRowContext(ctx,
+		`SELECT serial_num, model, ip FROM devices WHERE serial_num = ?`, serialNum,
+	).Scan(&d.SerialNum, &d.Model, &d.IP)
+	if errors.Is(err, sql.ErrNoRows) {
+		return device.Device{}, ErrNoSuchDevice
+	}
+	if err != nil {
+		return device.Device{}, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to get device", err)
+	}
+	return d, nil
+}
+
+func (s *DeviceStorage) CreateDevice(ctx context.Context, device device.Device) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO devices (serial_num, model, ip) VALUES (?, ?, ?)`,
+		device.SerialNum, device.Model, device.IP,
+	)
+	if isUniqueViolation(err) {
+		return ErrDeviceAlreadyExists
+	}
+	if err != nil {
+		return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to create device", err)
+	}
+	return nil
+}
+
+func (s *DeviceStorage) DeleteDeviceBySerialNum(ctx context.Context, serialNum string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM devices WHERE serial_num = ?`, serialNum)
+	if err != nil {
+		return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to delete device", err)
+	}
+	return checkAffected(res)
+}
+
+func (s *DeviceStorage) UpdateDevice(ctx context.Context, device device.Device) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE devices SET model = ?, ip = ? WHERE serial_num = ?`,
+		device.Model, device.IP, device.SerialNum,
+	)
+	if err != nil {
+		return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to update device", err)
+	}
+	return checkAffected(res)
+}
+
+// ListDevices pages through devices with keyset pagination on (sort field, serial_num).
+// The subnet filter can't be expressed in SQL, so rows are filtered while scanning
+// and further batches are read until the page is full or the table is exhausted.
+func (s *DeviceStorage) ListDevices(ctx context.Context, query device.ListQuery) (device.Page, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return device.Page{}, err
+	}
+	var after *device.Cursor
+	if query.Cursor != "" {
+		cursor, err := device.DecodeCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return device.Page{}, err
+		}
+		after = &cursor
+	}
+
+	// one extra row tells whether there is a next page
+	batchSize := query.Limit + 1
+	page := device.Page{Devices: make([]device.Device, 0, query.Limit)}
+	for {
+		batch, err := s.listBatch(ctx, query, after, batchSize)
+		if err != nil {
+			return device.Page{}, err
+		}
+		for _, d := range batch {
+			if !query.Matches(d) {
+				continue
+			}
+			if len(page.Devices) == query.Limit {
+				page.NextCursor = query.Sort.CursorAfter(page.Devices[query.Limit-1]).Encode()
+				return page, nil
+			}
+			page.Devices = append(page.Devices, d)
+		}
+		if len(batch) < batchSize {
+			return page, nil
+		}
+		cursor := query.Sort.CursorAfter(batch[len(batch)-1])
+		after = &cursor
+	}
+}
+
+// listBatch reads up to limit devices of the model filter that follow the cursor in the sort order.
+func (s *DeviceStorage) listBatch(ctx context.Context, query device.ListQuery, after *device.Cursor, limit int) ([]device.Device, error) {
+	column, op, direction := "serial_num", ">", "ASC"
+	if query.Sort.Field() == device.SortByModel {
+		column = "model"
+	}
+	if query.Sort.Descending() {
+		op, direction = "<", "DESC"
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	if query.Model != "" {
+		where = append(where, "model = ?")
+		args = append(args, query.Model)
+	}
+	if after != nil {
+		if column == "serial_num" {
+			where = append(where, "serial_num "+op+" ?")
+			args = append(args, after.SerialNum)
+		} else {
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND serial_num %[2]s ?))", column, op))
+			args = append(args, after.Key, after.Key, after.SerialNum)
+		}
+	}
+
+	stmt := "SELECT serial_num, model, ip FROM devices"
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+	stmt += fmt.Sprintf(" ORDER BY %s %s", column, direction)
+	if column != "serial_num" {
+		stmt += ", serial_num " + direction
+	}
+	stmt += " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to list devices", err)
+	}
+	defer rows.Close()
+
+	devices := make([]device.Device, 0, limit)
+	for rows.Next() {
+		var d device.Device
+		if err := rows.Scan(&d.SerialNum, &d.Model, &d.IP); err != nil {
+			return nil, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to scan device", err)
+		}
+		devices = append(devices, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to list devices", err)
+	}
+	return devices, nil
+}
+
+func (s *DeviceStorage) CountDevicesByModel(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT model, COUNT(*) FROM devices GROUP BY model`)
+	if err != nil {
+		return nil, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to count devices", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			model string
+			count int
+		)
+		if err := rows.Scan(&model, &count); err != nil {
+			return nil, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to scan device count", err)
+		}
+		counts[model] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to count devices", err)
+	}
+	return counts, nil
+}
+
+// Close closes the underlying database.
+func (s *DeviceStorage) Close() error {
+	return s.db.Close()
+}
+
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to count affected rows", err)
+	}
+	if n == 0 {
+		return ErrNoSuchDevice
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+// migrate applies embedded migrations that are newer than the recorded schema version.
+// Migration files are named "<version>_<description>.sql" and applied in version order.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	type migration struct {
+		version int
+		file    string
+	}
+	pending := make([]migration, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid migration name %q: %w", name, err)
+		}
+		if version > current {
+			pending = append(pending, migration{version: version, file: file})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].version < pending[j].version })
+
+	for _, m := range pending {
+		query, err := migrations.ReadFile(m.file)
+		if err != nil {
+			return err
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(query)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %s: %w", m.file, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, m.version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", m.file, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", m.file, err)
+		}
+	}
+	return nil
+}
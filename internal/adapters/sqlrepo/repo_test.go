@@ -0,0 +1,71 @@
+package sqlrepo
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/adapters/storagetest"
+	"homework/internal/app"
+	"homework/internal/device"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeviceStorage_CRUD(t *testing.T) {
+	storage, err := NewDeviceStorage(":memory:")
+	require.NoError(t, err)
+	defer storage.Close()
+
+	d := device.Device{SerialNum: "1235", Model: "HP", IP: "121.121.121.121"}
+
+	require.NoError(t, storage.CreateDevice(context.Background(), d))
+	assert.Equal(t, ErrDeviceAlreadyExists, storage.CreateDevice(context.Background(), d))
+
+	got, err := storage.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	require.NoError(t, err)
+	assert.Equal(t, d, got)
+
+	d.Model = "ASUS"
+	require.NoError(t, storage.UpdateDevice(context.Background(), d))
+	assert.Equal(t, ErrNoSuchDevice, storage.UpdateDevice(context.Background(), device.Device{SerialNum: "4444"}))
+
+	got, err = storage.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	require.NoError(t, err)
+	assert.Equal(t, d, got)
+
+	require.NoError(t, storage.DeleteDeviceBySerialNum(context.Background(), d.SerialNum))
+	assert.Equal(t, ErrNoSuchDevice, storage.DeleteDeviceBySerialNum(context.Background(), d.SerialNum))
+
+	_, err = storage.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	assert.Equal(t, ErrNoSuchDevice, err)
+}
+
+func TestDeviceStorage_PersistsAcrossRestart(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "devices.db")
+
+	storage, err := NewDeviceStorage(dsn)
+	require.NoError(t, err)
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "1111", Model: "HP", IP: "1.1.1.1"}))
+	require.NoError(t, storage.Close())
+
+	// migrations are applied only once, reopening must not fail on existing tables
+	reopened, err := NewDeviceStorage(dsn)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	got, err := reopened.GetDeviceBySerialNum(context.Background(), "1111")
+	require.NoError(t, err)
+	assert.Equal(t, "HP", got.Model)
+
+	var version int
+	require.NoError(t, reopened.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, 2, version)
+}
+
+func TestDeviceStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func() app.DeviceStorage {
+		storage, err := NewDeviceStorage(":memory:")
+		require.NoError(t, err)
+		return storage
+	})
+}
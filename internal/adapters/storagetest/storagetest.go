@@ -0,0 +1,312 @@
+// Package storagetest provides a conformance test suite for app.DeviceStorage implementations.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/app"
+	"homework/internal/device"
+	"io"
+	"sync"
+	"testing"
+)
+
+// Run checks that storages created by newStorage behave like the reference fakerepo implementation
+// and return errors of app.ErrNotFound and app.ErrConflict kinds for missing and duplicate devices.
+// Every subtest gets a fresh storage; storages implementing io.Closer are closed after the subtest.
+func Run(t *testing.T, newStorage func() app.DeviceStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s app.DeviceStorage)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateDuplicate", testCreateDuplicate},
+		{"GetMissing", testGetMissing},
+		{"Update", testUpdate},
+		{"UpdateMissing", testUpdateMissing},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"ConcurrentDuplicateCreate", testConcurrentDuplicateCreate},
+		{"ConcurrentMixedAccess", testConcurrentMixedAccess},
+		{"ListPagination", testListPagination},
+		{"ListFilter", testListFilter},
+		{"ListInvalidCursor", testListInvalidCursor},
+		{"CountByModel", testCountByModel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage()
+			if closer, ok := s.(io.Closer); ok {
+				t.Cleanup(func() { closer.Close() })
+			}
+			tt.fn(t, s)
+		})
+	}
+}
+
+func assertErrorIs(t *testing.T, err, target error, msgAndArgs ...interface{}) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		assert.Fail(t, fmt.Sprintf("error %v is not %v", err, target), msgAndArgs...)
+	}
+}
+
+func newDevice(i int) device.Device {
+	return device.Device{
+		SerialNum: fmt.Sprintf("SN%05d", i),
+		Model:     fmt.Sprintf("model%d", i%3),
+		IP:        fmt.Sprintf("10.0.%d.%d", i/256, i%256),
+	}
+}
+
+func testCreateAndGet(t *testing.T, s app.DeviceStorage) {
+	want := newDevice(1)
+	require.NoError(t, s.CreateDevice(context.Background(), want))
+
+	got, err := s.GetDeviceBySerialNum(context.Background(), want.SerialNum)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func testCreateDuplicate(t *testing.T, s app.DeviceStorage) {
+	d := newDevice(1)
+	require.NoError(t, s.CreateDevice(context.Background(), d))
+
+	duplicate := d
+	duplicate.Model = "other"
+	assertErrorIs(t, s.CreateDevice(context.Background(), duplicate), app.ErrConflict)
+
+	got, err := s.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	require.NoError(t, err)
+	assert.Equal(t, d, got, "failed create must not overwrite the device")
+}
+
+func testGetMissing(t *testing.T, s app.DeviceStorage) {
+	_, err := s.GetDeviceBySerialNum(context.Background(), "missing")
+	assertErrorIs(t, err, app.ErrNotFound)
+}
+
+func testUpdate(t *testing.T, s app.DeviceStorage) {
+	d := newDevice(1)
+	require.NoError(t, s.CreateDevice(context.Background(), d))
+
+	d.Model = "updated"
+	d.IP = "192.168.0.1"
+	require.NoError(t, s.UpdateDevice(context.Background(), d))
+
+	got, err := s.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	require.NoError(t, err)
+	assert.Equal(t, d, got)
+}
+
+func testUpdateMissing(t *testing.T, s app.DeviceStorage) {
+	d := newDevice(1)
+	assertErrorIs(t, s.UpdateDevice(context.Background(), d), app.ErrNotFound)
+
+	_, err := s.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	assertErrorIs(t, err, app.ErrNotFound, "failed update must not create the device")
+}
+
+func testDelete(t *testing.T, s app.DeviceStorage) {
+	d := newDevice(1)
+	require.NoError(t, s.CreateDevice(context.Background(), d))
+	require.NoError(t, s.DeleteDeviceBySerialNum(context.Background(), d.SerialNum))
+
+	_, err := s.GetDeviceBySerialNum(context.Background(), d.SerialNum)
+	assertErrorIs(t, err, app.ErrNotFound)
+
+	// serial number is free again after deletion
+	assert.NoError(t, s.CreateDevice(context.Background(), d))
+}
+
+func testDeleteMissing(t *testing.T, s app.DeviceStorage) {
+	assertErrorIs(t, s.DeleteDeviceBySerialNum(context.Background(), "missing"), app.ErrNotFound)
+}
+
+func testConcurrentCreate(t *testing.T, s app.DeviceStorage) {
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, s.CreateDevice(context.Background(), newDevice(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		got, err := s.GetDeviceBySerialNum(context.Background(), newDevice(i).SerialNum)
+		require.NoError(t, err)
+		assert.Equal(t, newDevice(i), got)
+	}
+}
+
+func testConcurrentDuplicateCreate(t *testing.T, s app.DeviceStorage) {
+	const n = 50
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.CreateDevice(context.Background(), newDevice(1))
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+				return
+			}
+			assertErrorIs(t, err, app.ErrConflict)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, created, "exactly one concurrent create must succeed")
+}
+
+func testConcurrentMixedAccess(t *testing.T, s app.DeviceStorage) {
+	const n = 100
+	for i := 0; i < 2*n; i++ {
+		require.NoError(t, s.CreateDevice(context.Background(), newDevice(i)))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			d := newDevice(i)
+			d.Model = "updated"
+			assert.NoError(t, s.UpdateDevice(context.Background(), d))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.GetDeviceBySerialNum(context.Background(), newDevice(i).SerialNum)
+			assert.NoError(t, err)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			// delete devices that are not touched by updates and reads
+			assert.NoError(t, s.DeleteDeviceBySerialNum(context.Background(), newDevice(n+i).SerialNum))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		got, err := s.GetDeviceBySerialNum(context.Background(), newDevice(i).SerialNum)
+		require.NoError(t, err)
+		assert.Equal(t, "updated", got.Model)
+
+		_, err = s.GetDeviceBySerialNum(context.Background(), newDevice(n+i).SerialNum)
+		assertErrorIs(t, err, app.ErrNotFound)
+	}
+}
+
+func listAll(t *testing.T, s app.DeviceStorage, query device.ListQuery) []device.Device {
+	var all []device.Device
+	for pages := 0; ; pages++ {
+		require.True(t, pages <= 100, "pagination does not terminate")
+		page, err := s.ListDevices(context.Background(), query)
+		require.NoError(t, err)
+		require.True(t, len(page.Devices) <= query.Limit || query.Limit == 0)
+		all = append(all, page.Devices...)
+		if page.NextCursor == "" {
+			return all
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func testListPagination(t *testing.T, s app.DeviceStorage) {
+	const n = 25
+	var want []device.Device
+	for i := 0; i < n; i++ {
+		d := newDevice(i)
+		require.NoError(t, s.CreateDevice(context.Background(), d))
+		want = append(want, d)
+	}
+
+	for _, order := range []device.SortOrder{
+		device.SortBySerialNum, device.SortBySerialNumDesc, device.SortByModel, device.SortByModelDesc,
+	} {
+		t.Run(string(order), func(t *testing.T) {
+			got := listAll(t, s, device.ListQuery{Sort: order, Limit: 4})
+			require.Len(t, got, n)
+			for i := 1; i < len(got); i++ {
+				assert.True(t, order.Less(got[i-1], got[i]), "%v must go before %v", got[i-1], got[i])
+			}
+			assert.ElementsMatch(t, want, got)
+		})
+	}
+
+	page, err := s.ListDevices(context.Background(), device.ListQuery{Limit: n})
+	require.NoError(t, err)
+	assert.Len(t, page.Devices, n)
+	assert.Empty(t, page.NextCursor, "exactly filled page must not have next cursor")
+}
+
+func testListFilter(t *testing.T, s app.DeviceStorage) {
+	for i := 0; i < 20; i++ {
+		require.NoError(t, s.CreateDevice(context.Background(), newDevice(i)))
+	}
+	require.NoError(t, s.CreateDevice(context.Background(), device.Device{SerialNum: "V6", Model: "model1", IP: "2001:db8::1"}))
+
+	byModel := listAll(t, s, device.ListQuery{Model: "model1", Limit: 3})
+	assert.Len(t, byModel, 8)
+	for _, d := range byModel {
+		assert.Equal(t, "model1", d.Model)
+	}
+
+	subnet, err := device.ParseSubnet("10.0.0.8/29")
+	require.NoError(t, err)
+	bySubnet := listAll(t, s, device.ListQuery{Subnet: subnet, Limit: 3})
+	assert.Equal(t, []device.Device{
+		newDevice(8), newDevice(9), newDevice(10), newDevice(11),
+		newDevice(12), newDevice(13), newDevice(14), newDevice(15),
+	}, bySubnet)
+
+	both := listAll(t, s, device.ListQuery{Model: "model1", Subnet: subnet, Limit: 2})
+	assert.Equal(t, []device.Device{newDevice(10), newDevice(13)}, both)
+
+	single, err := device.ParseSubnet("2001:db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, []device.Device{{SerialNum: "V6", Model: "model1", IP: "2001:db8::1"}},
+		listAll(t, s, device.ListQuery{Subnet: single, Limit: 10}))
+}
+
+func testListInvalidCursor(t *testing.T, s app.DeviceStorage) {
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.CreateDevice(context.Background(), newDevice(i)))
+	}
+
+	_, err := s.ListDevices(context.Background(), device.ListQuery{Cursor: "not a cursor"})
+	assertErrorIs(t, err, device.ErrInvalidCursor)
+
+	page, err := s.ListDevices(context.Background(), device.ListQuery{Sort: device.SortByModel, Limit: 1})
+	require.NoError(t, err)
+	_, err = s.ListDevices(context.Background(), device.ListQuery{Sort: device.SortBySerialNum, Cursor: page.NextCursor})
+	assertErrorIs(t, err, device.ErrInvalidCursor, "cursor issued for another sort order")
+}
+
+func testCountByModel(t *testing.T, s app.DeviceStorage) {
+	counts, err := s.CountDevicesByModel(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+
+	for i := 0; i < 7; i++ {
+		require.NoError(t, s.CreateDevice(context.Background(), newDevice(i)))
+	}
+	require.NoError(t, s.DeleteDeviceBySerialNum(context.Background(), newDevice(0).SerialNum))
+
+	counts, err = s.CountDevicesByModel(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"model0": 2, "model1": 2, "model2": 2}, counts)
+}
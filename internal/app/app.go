@@ -1,15 +1,25 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"homework/internal/device"
+	"homework/internal/tracing/spans"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.36.0 --name=DeviceStorage
 type DeviceStorage interface {
-	GetDeviceBySerialNum(serialNum string) (device.Device, error)
-	CreateDevice(device device.Device) error
-	DeleteDeviceBySerialNum(serialNum string) error
-	UpdateDevice(device device.Device) error
+	GetDeviceBySerialNum(ctx context.Context, serialNum string) (device.Device, error)
+	CreateDevice(ctx context.Context, device device.Device) error
+	DeleteDeviceBySerialNum(ctx context.Context, serialNum string) error
+	UpdateDevice(ctx context.Context, device device.Device) error
+	ListDevices(ctx context.Context, query device.ListQuery) (device.Page, error)
+	// CountDevicesByModel returns the number of devices of every model in the storage.
+	CountDevicesByModel(ctx context.Context) (map[string]int, error)
 }
 
 type DeviceService struct {
@@ -22,33 +32,86 @@ func NewService(storage DeviceStorage) *DeviceService {
 	}
 }
 
-func (s *DeviceService) GetDevice(serialNum string) (device.Device, error) {
-	d, err := s.storage.GetDeviceBySerialNum(serialNum)
+func (s *DeviceService) GetDevice(ctx context.Context, serialNum string) (_ device.Device, err error) {
+	ctx, span := startSpan(ctx, "GetDevice", attribute.String("device.serial_num", serialNum))
+	defer func() { spans.End(span, err) }()
+
+	d, err := s.storage.GetDeviceBySerialNum(ctx, serialNum)
 	if err != nil {
 		return device.Device{}, err
 	}
 	return d, nil
 }
 
-func (s *DeviceService) CreateDevice(device device.Device) error {
-	if err := s.storage.CreateDevice(device); err != nil {
+func (s *DeviceService) CreateDevice(ctx context.Context, device device.Device) (err error) {
+	ctx, span := startSpan(ctx, "CreateDevice", attribute.String("device.serial_num", device.SerialNum))
+	defer func() { spans.End(span, err) }()
+
+	if err := s.storage.CreateDevice(ctx, device); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *DeviceService) DeleteDevice(serialNum string) error {
-	if err := s.storage.DeleteDeviceBySerialNum(serialNum); err != nil {
+func (s *DeviceService) DeleteDevice(ctx context.Context, serialNum string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteDevice", attribute.String("device.serial_num", serialNum))
+	defer func() { spans.End(span, err) }()
+
+	if err := s.storage.DeleteDeviceBySerialNum(ctx, serialNum); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *DeviceService) UpdateDevice(device device.Device) error {
+func (s *DeviceService) UpdateDevice(ctx context.Context, device device.Device) (err error) {
+	ctx, span := startSpan(ctx, "UpdateDevice", attribute.String("device.serial_num", device.SerialNum))
+	defer func() { spans.End(span, err) }()
 
-	err := s.storage.UpdateDevice(device)
+	err = s.storage.UpdateDevice(ctx, device)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (s *DeviceService) ListDevices(ctx context.Context, query device.ListQuery) (_ device.Page, err error) {
+	ctx, span := startSpan(ctx, "ListDevices")
+	defer func() { spans.End(span, err) }()
+
+	query, err = query.Normalize()
+	if err != nil {
+		return device.Page{}, InvalidListQuery(err)
+	}
+	if query.Cursor != "" {
+		if _, err := device.DecodeCursor(query.Cursor, query.Sort); err != nil {
+			return device.Page{}, InvalidListQuery(err)
+		}
+	}
+	page, err := s.storage.ListDevices(ctx, query)
+	if err != nil {
+		return device.Page{}, err
+	}
+	return page, nil
+}
+
+// listQueryFields describe the list query errors of the device package as field errors.
+var listQueryFields = map[error]FieldError{
+	device.ErrInvalidCursor:    {Field: "cursor", Rule: "format", Message: "is invalid"},
+	device.ErrInvalidSortOrder: {Field: "sort", Rule: "oneOf", Message: "is invalid"},
+	device.ErrInvalidLimit:     {Field: "limit", Rule: "range", Message: "should be between 1 and 1000"},
+	device.ErrInvalidSubnet:    {Field: "ip", Rule: "format", Message: "should be an IP address or a CIDR"},
+}
+
+// InvalidListQuery turns an error of parsing or normalizing a device.ListQuery into a validation error.
+func InvalidListQuery(err error) *Error {
+	for cause, field := range listQueryFields {
+		if errors.Is(err, cause) {
+			return &Error{Kind: ErrValidation, Code: "invalid_" + field.Field, Message: err.Error(), Fields: []FieldError{field}}
+		}
+	}
+	return NewError(ErrValidation, "invalid_list_query", err.Error())
+}
+
+func startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer("homework/internal/app").Start(ctx, "DeviceService."+method, trace.WithAttributes(attrs...))
+}
@@ -1,10 +1,12 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/mock"
-	"homework/internal/adapters/fakerepo"
 	"homework/internal/app/mocks"
 	"homework/internal/device"
+	"reflect"
 	"testing"
 )
 
@@ -17,15 +19,15 @@ func TestCreateDevice(t *testing.T) {
 		Model:     "model1",
 		IP:        "1.1.1.1",
 	}
-	storageMock.On("CreateDevice", wantDevice).
+	storageMock.On("CreateDevice", mock.Anything, wantDevice).
 		Return(nil)
-	err := service.CreateDevice(wantDevice)
+	err := service.CreateDevice(context.Background(), wantDevice)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	storageMock.On("GetDeviceBySerialNum", wantDevice.SerialNum).
+	storageMock.On("GetDeviceBySerialNum", mock.Anything, wantDevice.SerialNum).
 		Return(wantDevice, nil)
-	gotDevice, err := service.GetDevice(wantDevice.SerialNum)
+	gotDevice, err := service.GetDevice(context.Background(), wantDevice.SerialNum)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -57,18 +59,18 @@ func TestCreateMultipleDevices(t *testing.T) {
 	}
 
 	for _, d := range devices {
-		storageMock.On("CreateDevice", d).
+		storageMock.On("CreateDevice", mock.Anything, d).
 			Return(nil)
-		err := service.CreateDevice(d)
+		err := service.CreateDevice(context.Background(), d)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
 	}
 
 	for _, wantDevice := range devices {
-		storageMock.On("GetDeviceBySerialNum", wantDevice.SerialNum).
+		storageMock.On("GetDeviceBySerialNum", mock.Anything, wantDevice.SerialNum).
 			Return(wantDevice, nil)
-		gotDevice, err := service.GetDevice(wantDevice.SerialNum)
+		gotDevice, err := service.GetDevice(context.Background(), wantDevice.SerialNum)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -87,15 +89,15 @@ func TestCreateDuplicate(t *testing.T) {
 		Model:     "model1",
 		IP:        "1.1.1.1",
 	}
-	storageMock.On("CreateDevice", wantDevice).
+	storageMock.On("CreateDevice", mock.Anything, wantDevice).
 		Return(nil).Once()
-	err := service.CreateDevice(wantDevice)
+	err := service.CreateDevice(context.Background(), wantDevice)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	storageMock.On("CreateDevice", wantDevice).
-		Return(fakerepo.ErrDeviceAlreadyExists).Once()
-	err = service.CreateDevice(wantDevice)
+	storageMock.On("CreateDevice", mock.Anything, wantDevice).
+		Return(ErrConflict).Once()
+	err = service.CreateDevice(context.Background(), wantDevice)
 	if err == nil {
 		t.Errorf("want error, but got nil")
 	}
@@ -110,17 +112,17 @@ func TestGetDeviceUnexisting(t *testing.T) {
 		Model:     "model1",
 		IP:        "1.1.1.1",
 	}
-	storageMock.On("CreateDevice", wantDevice).
+	storageMock.On("CreateDevice", mock.Anything, wantDevice).
 		Return(nil).Once()
-	err := service.CreateDevice(wantDevice)
+	err := service.CreateDevice(context.Background(), wantDevice)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	storageMock.On("GetDeviceBySerialNum", wantDevice.SerialNum).
+	storageMock.On("GetDeviceBySerialNum", mock.Anything, wantDevice.SerialNum).
 		Return(wantDevice, nil).Maybe()
-	storageMock.On("GetDeviceBySerialNum", mock.Anything).
-		Return(device.Device{}, fakerepo.ErrNoSuchDevice)
-	_, err = service.GetDevice("1")
+	storageMock.On("GetDeviceBySerialNum", mock.Anything, mock.Anything).
+		Return(device.Device{}, ErrNotFound)
+	_, err = service.GetDevice(context.Background(), "1")
 	if err == nil {
 		t.Error("want error, but got nil")
 	}
@@ -135,23 +137,23 @@ func TestDeleteDevice(t *testing.T) {
 		IP:        "1.1.1.1",
 	}
 
-	storageMock.On("CreateDevice", newDevice).
+	storageMock.On("CreateDevice", mock.Anything, newDevice).
 		Return(nil).Once()
 
-	err := service.CreateDevice(newDevice)
+	err := service.CreateDevice(context.Background(), newDevice)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	storageMock.On("DeleteDeviceBySerialNum", newDevice.SerialNum).
+	storageMock.On("DeleteDeviceBySerialNum", mock.Anything, newDevice.SerialNum).
 		Return(nil).Once()
-	err = service.DeleteDevice(newDevice.SerialNum)
+	err = service.DeleteDevice(context.Background(), newDevice.SerialNum)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	storageMock.On("GetDeviceBySerialNum", newDevice.SerialNum).
-		Return(device.Device{}, fakerepo.ErrNoSuchDevice)
-	_, err = service.GetDevice(newDevice.SerialNum)
+	storageMock.On("GetDeviceBySerialNum", mock.Anything, newDevice.SerialNum).
+		Return(device.Device{}, ErrNotFound)
+	_, err = service.GetDevice(context.Background(), newDevice.SerialNum)
 	if err == nil {
 		t.Error("want error, but got nil")
 	}
@@ -161,9 +163,9 @@ func TestDeleteDeviceUnexisting(t *testing.T) {
 	storageMock := mocks.NewDeviceStorage(t)
 	service := NewService(storageMock)
 
-	storageMock.On("DeleteDeviceBySerialNum", mock.Anything).
-		Return(fakerepo.ErrNoSuchDevice)
-	err := service.DeleteDevice("123")
+	storageMock.On("DeleteDeviceBySerialNum", mock.Anything, mock.Anything).
+		Return(ErrNotFound)
+	err := service.DeleteDevice(context.Background(), "123")
 	if err == nil {
 		t.Errorf("want error, but got nil")
 	}
@@ -177,9 +179,9 @@ func TestUpdateDevice(t *testing.T) {
 		Model:     "model1",
 		IP:        "1.1.1.1",
 	}
-	storageMock.On("CreateDevice", testDevice).
+	storageMock.On("CreateDevice", mock.Anything, testDevice).
 		Return(nil).Once()
-	err := service.CreateDevice(testDevice)
+	err := service.CreateDevice(context.Background(), testDevice)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -189,15 +191,15 @@ func TestUpdateDevice(t *testing.T) {
 		Model:     "model1",
 		IP:        "1.1.1.2",
 	}
-	storageMock.On("UpdateDevice", newDevice).
+	storageMock.On("UpdateDevice", mock.Anything, newDevice).
 		Return(nil).Once()
-	err = service.UpdateDevice(newDevice)
+	err = service.UpdateDevice(context.Background(), newDevice)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-	storageMock.On("GetDeviceBySerialNum", newDevice.SerialNum).
+	storageMock.On("GetDeviceBySerialNum", mock.Anything, newDevice.SerialNum).
 		Return(newDevice, nil)
-	gotDevice, err := service.GetDevice(newDevice.SerialNum)
+	gotDevice, err := service.GetDevice(context.Background(), newDevice.SerialNum)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -215,10 +217,10 @@ func TestUpdateDeviceUnexsting(t *testing.T) {
 		Model:     "model1",
 		IP:        "1.1.1.1",
 	}
-	storageMock.On("CreateDevice", testDevice).
+	storageMock.On("CreateDevice", mock.Anything, testDevice).
 		Return(nil).Once()
 
-	err := service.CreateDevice(testDevice)
+	err := service.CreateDevice(context.Background(), testDevice)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -228,10 +230,54 @@ func TestUpdateDeviceUnexsting(t *testing.T) {
 		Model:     "model1",
 		IP:        "1.1.1.2",
 	}
-	storageMock.On("UpdateDevice", newDevice).
-		Return(fakerepo.ErrNoSuchDevice).Once()
-	err = service.UpdateDevice(newDevice)
+	storageMock.On("UpdateDevice", mock.Anything, newDevice).
+		Return(ErrNotFound).Once()
+	err = service.UpdateDevice(context.Background(), newDevice)
 	if err == nil {
 		t.Errorf("want err, but got nil")
 	}
 }
+
+func TestListDevices(t *testing.T) {
+	storageMock := mocks.NewDeviceStorage(t)
+	service := NewService(storageMock)
+
+	query := device.ListQuery{Model: "model1", Sort: device.SortBySerialNum, Limit: 1}
+	wantPage := device.Page{
+		Devices:    []device.Device{{SerialNum: "123", Model: "model1", IP: "1.1.1.1"}},
+		NextCursor: "next",
+	}
+	storageMock.On("ListDevices", mock.Anything, query).
+		Return(wantPage, nil).Once()
+	gotPage, err := service.ListDevices(context.Background(), device.ListQuery{Model: "model1", Limit: 1})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(wantPage, gotPage) {
+		t.Errorf("want page %+#v not equal got %+#v", wantPage, gotPage)
+	}
+}
+
+func TestListDevicesInvalidQuery(t *testing.T) {
+	storageMock := mocks.NewDeviceStorage(t)
+	service := NewService(storageMock)
+
+	for _, tt := range []struct {
+		query device.ListQuery
+		field string
+	}{
+		{query: device.ListQuery{Cursor: "bad"}, field: "cursor"},
+		{query: device.ListQuery{Sort: "ip"}, field: "sort"},
+		{query: device.ListQuery{Limit: device.MaxListLimit + 1}, field: "limit"},
+	} {
+		_, err := service.ListDevices(context.Background(), tt.query)
+		var appErr *Error
+		if !errors.As(err, &appErr) || !errors.Is(err, ErrValidation) {
+			t.Errorf("want validation error for %+#v, but got %v", tt.query, err)
+			continue
+		}
+		if len(appErr.Fields) != 1 || appErr.Fields[0].Field != tt.field {
+			t.Errorf("want error of field %s for %+#v, but got %+v", tt.field, tt.query, appErr.Fields)
+		}
+	}
+}
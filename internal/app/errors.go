@@ -0,0 +1,89 @@
+package app
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kinds of domain errors. Storage adapters and validators wrap them into their own errors,
+// so callers can tell what went wrong regardless of the storage in use.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("unavailable")
+	ErrForbidden   = errors.New("forbidden")
+)
+
+// Error is a domain error of one of the kinds above.
+type Error struct {
+	Kind error
+	// Code is a machine-readable identifier of the error, e.g. "device_not_found".
+	Code string
+	// Message is safe to show to API clients.
+	Message string
+	// Fields describe which fields failed validation.
+	Fields []FieldError
+	// Err is the underlying cause, it is not shown to API clients.
+	Err error
+}
+
+// FieldError describes a violation of a validation rule by a single field of the request.
+type FieldError struct {
+	Field string `json:"field"`
+	// Rule is a machine-readable name of the violated rule, e.g. "required".
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (f FieldError) String() string {
+	return f.Field + ": " + f.Message
+}
+
+// NewError creates an error of the given kind with the message returned by Error.
+func NewError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NewFieldError creates a validation error of a single field.
+func NewFieldError(code string, field FieldError) *Error {
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    code,
+		Message: field.Field + " " + field.Message,
+		Fields:  []FieldError{field},
+	}
+}
+
+// NewValidationError creates a validation error listing all violations found in the request.
+func NewValidationError(fields []FieldError) *Error {
+	messages := make([]string, 0, len(fields))
+	for _, f := range fields {
+		messages = append(messages, f.String())
+	}
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    "validation_failed",
+		Message: strings.Join(messages, "; "),
+		Fields:  fields,
+	}
+}
+
+// Wrap creates an error of the given kind caused by err.
+func Wrap(kind error, code, message string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
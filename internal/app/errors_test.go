@@ -0,0 +1,39 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	notFound := NewError(ErrNotFound, "device_not_found", "there is no such device")
+	if !errors.Is(notFound, ErrNotFound) || errors.Is(notFound, ErrConflict) {
+		t.Errorf("want error of kind %v only", ErrNotFound)
+	}
+	if notFound.Error() != "there is no such device" {
+		t.Errorf("unexpected message %q", notFound.Error())
+	}
+
+	wrapped := Wrap(ErrUnavailable, "storage_unavailable", "failed to get device", io.ErrUnexpectedEOF)
+	if !errors.Is(wrapped, ErrUnavailable) || !errors.Is(wrapped, io.ErrUnexpectedEOF) {
+		t.Errorf("want error of kind %v caused by %v", ErrUnavailable, io.ErrUnexpectedEOF)
+	}
+	if wrapped.Error() != "failed to get device: unexpected EOF" {
+		t.Errorf("unexpected message %q", wrapped.Error())
+	}
+
+	var appErr *Error
+	if !errors.As(NewFieldError("invalid_ip", FieldError{Field: "ip", Rule: "ip", Message: "is not a valid address"}), &appErr) ||
+		appErr.Fields[0].Field != "ip" || appErr.Message != "ip is not a valid address" {
+		t.Errorf("want field error for ip, got %+#v", appErr)
+	}
+
+	invalid := NewValidationError([]FieldError{
+		{Field: "model", Rule: "required", Message: "required"},
+		{Field: "ip", Rule: "ip", Message: "not a valid address"},
+	})
+	if !errors.Is(invalid, ErrValidation) || invalid.Error() != "model: required; ip: not a valid address" {
+		t.Errorf("unexpected validation error %q", invalid.Error())
+	}
+}
@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	models "homework/internal/device"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,13 +15,39 @@ type DeviceStorage struct {
 	mock.Mock
 }
 
-// CreateDevice provides a mock function with given fields: device
-func (_m *DeviceStorage) CreateDevice(device models.Device) error {
-	ret := _m.Called(device)
+// CountDevicesByModel provides a mock function with given fields: ctx
+func (_m *DeviceStorage) CountDevicesByModel(ctx context.Context) (map[string]int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[string]int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (map[string]int, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) map[string]int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateDevice provides a mock function with given fields: ctx, device
+func (_m *DeviceStorage) CreateDevice(ctx context.Context, device models.Device) error {
+	ret := _m.Called(ctx, device)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Device) error); ok {
-		r0 = rf(device)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Device) error); ok {
+		r0 = rf(ctx, device)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -27,13 +55,13 @@ func (_m *DeviceStorage) CreateDevice(device models.Device) error {
 	return r0
 }
 
-// DeleteDeviceBySerialNum provides a mock function with given fields: serialNum
-func (_m *DeviceStorage) DeleteDeviceBySerialNum(serialNum string) error {
-	ret := _m.Called(serialNum)
+// DeleteDeviceBySerialNum provides a mock function with given fields: ctx, serialNum
+func (_m *DeviceStorage) DeleteDeviceBySerialNum(ctx context.Context, serialNum string) error {
+	ret := _m.Called(ctx, serialNum)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(serialNum)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, serialNum)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -41,23 +69,47 @@ func (_m *DeviceStorage) DeleteDeviceBySerialNum(serialNum string) error {
 	return r0
 }
 
-// GetDeviceBySerialNum provides a mock function with given fields: serialNum
-func (_m *DeviceStorage) GetDeviceBySerialNum(serialNum string) (models.Device, error) {
-	ret := _m.Called(serialNum)
+// GetDeviceBySerialNum provides a mock function with given fields: ctx, serialNum
+func (_m *DeviceStorage) GetDeviceBySerialNum(ctx context.Context, serialNum string) (models.Device, error) {
+	ret := _m.Called(ctx, serialNum)
 
 	var r0 models.Device
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (models.Device, error)); ok {
-		return rf(serialNum)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (models.Device, error)); ok {
+		return rf(ctx, serialNum)
 	}
-	if rf, ok := ret.Get(0).(func(string) models.Device); ok {
-		r0 = rf(serialNum)
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Device); ok {
+		r0 = rf(ctx, serialNum)
 	} else {
 		r0 = ret.Get(0).(models.Device)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(serialNum)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, serialNum)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDevices provides a mock function with given fields: ctx, query
+func (_m *DeviceStorage) ListDevices(ctx context.Context, query models.ListQuery) (models.Page, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 models.Page
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.ListQuery) (models.Page, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.ListQuery) models.Page); ok {
+		r0 = rf(ctx, query)
+	} else {
+		r0 = ret.Get(0).(models.Page)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.ListQuery) error); ok {
+		r1 = rf(ctx, query)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -65,13 +117,13 @@ func (_m *DeviceStorage) GetDeviceBySerialNum(serialNum string) (models.Device,
 	return r0, r1
 }
 
-// UpdateDevice provides a mock function with given fields: device
-func (_m *DeviceStorage) UpdateDevice(device models.Device) error {
-	ret := _m.Called(device)
+// UpdateDevice provides a mock function with given fields: ctx, device
+func (_m *DeviceStorage) UpdateDevice(ctx context.Context, device models.Device) error {
+	ret := _m.Called(ctx, device)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Device) error); ok {
-		r0 = rf(device)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Device) error); ok {
+		r0 = rf(ctx, device)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/app"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope limits what an API key may do.
+type Scope string
+
+const (
+	ScopeDevicesRead  Scope = "devices:read"
+	ScopeDevicesWrite Scope = "devices:write"
+	// ScopeAdmin allows everything, including managing API keys.
+	ScopeAdmin Scope = "admin"
+)
+
+// AllScopes are the valid scopes.
+var AllScopes = []Scope{ScopeDevicesRead, ScopeDevicesWrite, ScopeAdmin}
+
+const apiKeyPrefix = "dk"
+
+var (
+	ErrNoSuchAPIKey  = app.NewError(app.ErrNotFound, "api_key_not_found", "there is no such API key")
+	ErrInvalidScope  = app.NewError(app.ErrValidation, "invalid_scope", "unknown scope, want devices:read, devices:write or admin")
+	ErrNoScopes      = app.NewError(app.ErrValidation, "no_scopes", "API key needs at least one scope")
+	ErrNoLabel       = app.NewError(app.ErrValidation, "no_label", "API key needs a label")
+	ErrExpiresInPast = app.NewError(app.ErrValidation, "expires_in_past", "expiry time is in the past")
+)
+
+// KeyAuthenticator checks API keys.
+type KeyAuthenticator interface {
+	// AuthenticateKey returns ErrInvalidCredentials for unknown, revoked and expired keys.
+	AuthenticateKey(key string) (Principal, error)
+}
+
+// APIKey describes an issued key. The key itself is never stored, only its SHA-256 hash.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt of nil means the key never expires.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Hash      string     `json:"-"`
+}
+
+// Expired tells whether the key is expired at now.
+func (k APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// storedAPIKey is the representation of APIKey in the keys file.
+type storedAPIKey struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+// APIKeys issues and checks API keys of the form "dk_<id>_<secret>".
+// Keys are kept in a JSON file, so they survive restarts.
+type APIKeys struct {
+	path string
+	now  func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewAPIKeys loads keys from the file at path, a missing file means there are no keys yet.
+func NewAPIKeys(path string) (*APIKeys, error) {
+	s := &APIKeys{path: path, now: time.Now, keys: make(map[string]APIKey)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read API keys file: %w", err)
+	}
+	var stored []storedAPIKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("decode API keys file: %w", err)
+	}
+	for _, k := range stored {
+		k.APIKey.Hash = k.Hash
+		s.keys[k.ID] = k.APIKey
+	}
+	return s, nil
+}
+
+// Create issues a new key, a zero expiresAt means the key never expires.
+// The returned key string is shown once and can't be recovered.
+func (s *APIKeys) Create(label string, scopes []Scope, expiresAt time.Time) (APIKey, string, error) {
+	if strings.TrimSpace(label) == "" {
+		return APIKey{}, "", ErrNoLabel
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, "", ErrNoScopes
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return APIKey{}, "", ErrInvalidScope
+		}
+	}
+	now := s.now()
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return APIKey{}, "", ErrExpiresInPast
+	}
+
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	key := apiKeyPrefix + "_" + id + "_" + secret
+
+	apiKey := APIKey{
+		ID:        id,
+		Label:     label,
+		Scopes:    scopes,
+		CreatedAt: now.UTC(),
+		Hash:      hashKey(key),
+	}
+	if !expiresAt.IsZero() {
+		expiresAt = expiresAt.UTC()
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[id] = apiKey
+	if err := s.save(); err != nil {
+		delete(s.keys, id)
+		return APIKey{}, "", err
+	}
+	return apiKey, key, nil
+}
+
+// List returns all keys ordered by creation time.
+func (s *APIKeys) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Revoke deletes the key, it stops working immediately.
+func (s *APIKeys) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrNoSuchAPIKey
+	}
+	delete(s.keys, id)
+	if err := s.save(); err != nil {
+		s.keys[id] = k
+		return err
+	}
+	return nil
+}
+
+func (s *APIKeys) AuthenticateKey(key string) (Principal, error) {
+	prefix, rest, _ := strings.Cut(key, "_")
+	id, _, _ := strings.Cut(rest, "_")
+
+	s.mu.RLock()
+	k, ok := s.keys[id]
+	s.mu.RUnlock()
+
+	hash := hashKey(key)
+	if prefix != apiKeyPrefix || !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(k.Hash)) != 1 || k.Expired(s.now()) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Name: "apikey:" + k.ID, Method: "apikey", Scopes: k.Scopes}, nil
+}
+
+// save writes all keys to the file, it must be called with the lock held.
+func (s *APIKeys) save() error {
+	stored := make([]storedAPIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		stored = append(stored, storedAPIKey{APIKey: k, Hash: k.Hash})
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].ID < stored[j].ID })
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("write API keys file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write API keys file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write API keys file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("write API keys file: %w", err)
+	}
+	return nil
+}
+
+func validScope(scope Scope) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate API key: %w", err)
+	}
+	return encode(b), nil
+}
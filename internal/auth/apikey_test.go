@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	keys, err := NewAPIKeys(path)
+	require.NoError(t, err)
+
+	apiKey, secret, err := keys.Create("ci", []Scope{ScopeDevicesRead}, time.Time{})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "dk_"+apiKey.ID+"_"))
+	assert.Nil(t, apiKey.ExpiresAt)
+
+	principal, err := keys.AuthenticateKey(secret)
+	require.NoError(t, err)
+	assert.Equal(t, Principal{Name: "apikey:" + apiKey.ID, Method: "apikey", Scopes: []Scope{ScopeDevicesRead}}, principal)
+	assert.True(t, principal.HasScope(ScopeDevicesRead))
+	assert.False(t, principal.HasScope(ScopeDevicesWrite))
+
+	_, err = keys.AuthenticateKey(secret + "x")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = keys.AuthenticateKey("garbage")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), secret, "the key itself must not be stored")
+
+	restored, err := NewAPIKeys(path)
+	require.NoError(t, err)
+	_, err = restored.AuthenticateKey(secret)
+	assert.NoError(t, err, "keys survive restart")
+
+	require.NoError(t, restored.Revoke(apiKey.ID))
+	_, err = restored.AuthenticateKey(secret)
+	assert.Equal(t, ErrInvalidCredentials, err)
+	assert.Equal(t, ErrNoSuchAPIKey, restored.Revoke(apiKey.ID))
+	assert.Empty(t, restored.List())
+}
+
+func TestAPIKeys_Expiry(t *testing.T) {
+	keys, err := NewAPIKeys(filepath.Join(t.TempDir(), "api_keys.json"))
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return now }
+
+	_, secret, err := keys.Create("collector", []Scope{ScopeDevicesWrite}, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	_, err = keys.AuthenticateKey(secret)
+	assert.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	_, err = keys.AuthenticateKey(secret)
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestAPIKeys_CreateInvalid(t *testing.T) {
+	keys, err := NewAPIKeys(filepath.Join(t.TempDir(), "api_keys.json"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		label     string
+		scopes    []Scope
+		expiresAt time.Time
+		expected  error
+	}{
+		{name: "No label", scopes: []Scope{ScopeAdmin}, expected: ErrNoLabel},
+		{name: "No scopes", label: "ci", expected: ErrNoScopes},
+		{name: "Unknown scope", label: "ci", scopes: []Scope{"devices:delete"}, expected: ErrInvalidScope},
+		{name: "Expired", label: "ci", scopes: []Scope{ScopeAdmin}, expiresAt: time.Now().Add(-time.Hour), expected: ErrExpiresInPast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := keys.Create(tt.label, tt.scopes, tt.expiresAt)
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Package auth authenticates API callers and carries the authenticated principal in the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/internal/config"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrNoCredentials      = errors.New("neither user and password nor htpasswd file are configured")
+)
+
+// Principal is the authenticated caller of the API.
+type Principal struct {
+	// Name identifies the caller, e.g. the login of a basic auth user.
+	Name string
+	// Method is how the caller authenticated, e.g. "basic".
+	Method string
+	// Scopes the caller is granted.
+	Scopes []Scope
+	// Roles the caller claims, e.g. from the roles claim of a JWT.
+	Roles []string
+	// Claims of the token the caller authenticated with, nil for other methods.
+	Claims map[string]any
+}
+
+// HasScope tells whether the principal is granted the scope, admin is granted every scope.
+func (p Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultScopes are granted to basic auth users and client certificates that aren't admins.
+var DefaultScopes = []Scope{ScopeDevicesRead, ScopeDevicesWrite}
+
+// Admins are the names of basic auth users and client certificates granted every scope.
+type Admins map[string]struct{}
+
+func NewAdmins(names []string) Admins {
+	admins := make(Admins, len(names))
+	for _, name := range names {
+		admins[name] = struct{}{}
+	}
+	return admins
+}
+
+// Scopes returns the scopes granted to the user or certificate with the name.
+func (a Admins) Scopes(name string) []Scope {
+	if _, ok := a[name]; ok {
+		return AllScopes
+	}
+	return DefaultScopes
+}
+
+// Authenticator checks username and password credentials.
+type Authenticator interface {
+	// Authenticate returns ErrInvalidCredentials if the credentials don't match.
+	Authenticate(username, password string) (Principal, error)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// NewAuthenticator returns an authenticator backed by the htpasswd file if it is configured,
+// or by the single user from the config otherwise.
+// The returned authenticator is an io.Closer when it watches the file for changes.
+func NewAuthenticator(cfg config.HTTPServer) (Authenticator, error) {
+	if cfg.HtpasswdFile != "" {
+		return NewHtpasswdFile(cfg.HtpasswdFile, cfg.HtpasswdReloadInterval, NewAdmins(cfg.Admins))
+	}
+	if cfg.User == "" || cfg.Password == "" {
+		return nil, ErrNoCredentials
+	}
+	if isHash(cfg.Password) {
+		if err := checkHash(cfg.Password); err != nil {
+			return nil, fmt.Errorf("password of %q: %w", cfg.User, err)
+		}
+	}
+	return NewStaticAuthenticator(cfg.User, cfg.Password, NewAdmins(cfg.Admins)), nil
+}
+
+// StaticAuthenticator accepts a single user.
+type StaticAuthenticator struct {
+	username string
+	password string
+	admins   Admins
+}
+
+// NewStaticAuthenticator accepts username with password,
+// which is either a bcrypt or argon2id hash or a plain text password.
+func NewStaticAuthenticator(username, password string, admins Admins) *StaticAuthenticator {
+	return &StaticAuthenticator{username: username, password: password, admins: admins}
+}
+
+func (a *StaticAuthenticator) Authenticate(username, password string) (Principal, error) {
+	// both checks always run, so the response time doesn't tell whether the user exists
+	userOK := constantTimeEqual(username, a.username)
+	passwordOK := verifyPassword(a.password, password)
+	if !userOK || !passwordOK {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Name: username, Method: "basic", Scopes: a.admins.Scopes(username)}, nil
+}
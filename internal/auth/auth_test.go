@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"homework/internal/config"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bcryptHash(t *testing.T, password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func argon2idHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 8*1024, 1, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, 8*1024, 1, 1,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestStaticAuthenticator(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   string
+		username string
+		password string
+		valid    bool
+	}{
+		{name: "Plain text", stored: "secret", username: "admin", password: "secret", valid: true},
+		{name: "Plain text wrong password", stored: "secret", username: "admin", password: "secreT"},
+		{name: "Wrong user", stored: "secret", username: "root", password: "secret"},
+		{name: "Bcrypt", stored: bcryptHash(t, "secret"), username: "admin", password: "secret", valid: true},
+		{name: "Bcrypt wrong password", stored: bcryptHash(t, "secret"), username: "admin", password: "other"},
+		{name: "Argon2id", stored: argon2idHash("secret"), username: "admin", password: "secret", valid: true},
+		{name: "Argon2id wrong password", stored: argon2idHash("secret"), username: "admin", password: "other"},
+		{name: "Malformed argon2id", stored: "$argon2id$v=19$broken", username: "admin", password: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := NewStaticAuthenticator("admin", tt.stored, nil).Authenticate(tt.username, tt.password)
+			if !tt.valid {
+				assert.Equal(t, ErrInvalidCredentials, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, Principal{Name: "admin", Method: "basic", Scopes: DefaultScopes}, principal)
+		})
+	}
+}
+
+func TestVerifyArgon2id(t *testing.T) {
+	hash := argon2idHash("secret")
+	withParams := func(params string) string {
+		return strings.Replace(hash, "m=8192,t=1,p=1", params, 1)
+	}
+	parts := strings.Split(hash, "$")
+	salt, key := parts[4], parts[5]
+	withParts := func(salt, key string) string {
+		return strings.Join(append(parts[:4:4], salt, key), "$")
+	}
+	tests := []struct {
+		name    string
+		hash    string
+		valid   bool
+		wantErr bool
+	}{
+		{name: "Valid", hash: hash, valid: true},
+		{name: "Zero iterations", hash: withParams("m=8192,t=0,p=1"), wantErr: true},
+		{name: "Zero threads", hash: withParams("m=8192,t=1,p=0"), wantErr: true},
+		{name: "Memory above the limit", hash: withParams("m=4194304,t=1,p=1"), wantErr: true},
+		{name: "Empty salt", hash: withParts("", key), wantErr: true},
+		{name: "Short salt", hash: withParts("c2FsdA", key), wantErr: true},
+		{name: "Non-base64 salt", hash: withParts("not base64!", key), wantErr: true},
+		{name: "Empty key", hash: withParts(salt, ""), wantErr: true},
+		{name: "Short key", hash: withParts(salt, "c2hvcnQga2V5"), wantErr: true},
+		{name: "Non-base64 key", hash: withParts(salt, "not base64!"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := verifyArgon2id(tt.hash, "secret")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.valid, valid)
+		})
+	}
+}
+
+func TestHtpasswdFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	content := "# engineers\nalice:" + bcryptHash(t, "alice-pass") + "\n\nbot:" + argon2idHash("bot-pass") + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	f, err := NewHtpasswdFile(path, 0, NewAdmins([]string{"alice"}))
+	require.NoError(t, err)
+	defer f.Close()
+
+	principal, err := f.Authenticate("alice", "alice-pass")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", principal.Name)
+	assert.Equal(t, AllScopes, principal.Scopes, "admins are granted every scope")
+
+	principal, err = f.Authenticate("bot", "bot-pass")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultScopes, principal.Scopes)
+
+	_, err = f.Authenticate("alice", "bot-pass")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = f.Authenticate("mallory", "alice-pass")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestHtpasswdFile_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Plain text password", content: "alice:secret\n"},
+		{name: "Missing separator", content: "alice\n"},
+		{name: "Empty user", content: ":$2a$10$abc\n"},
+		{name: "Argon2id without key", content: "alice:" + strings.TrimSuffix(argon2idHash("secret"), "$"+strings.Split(argon2idHash("secret"), "$")[5]) + "$\n"},
+		{name: "Truncated bcrypt", content: "alice:$2a$10$abc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "htpasswd")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			_, err := NewHtpasswdFile(path, 0, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// replaceFile writes the file atomically, so the watcher never reads it half written.
+func replaceFile(t *testing.T, path, content string) {
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestHtpasswdFile_HotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	require.NoError(t, os.WriteFile(path, []byte("alice:"+bcryptHash(t, "old")+"\n"), 0o600))
+
+	f, err := NewHtpasswdFile(path, 10*time.Millisecond, nil)
+	require.NoError(t, err)
+	defer f.Close()
+
+	replaceFile(t, path, "alice:"+bcryptHash(t, "new")+"\nbob:"+bcryptHash(t, "bob")+"\n")
+
+	var reloaded bool
+	for i := 0; i < 200 && !reloaded; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, err := f.Authenticate("bob", "bob")
+		reloaded = err == nil
+	}
+	require.True(t, reloaded, "file was not reloaded")
+
+	_, err = f.Authenticate("alice", "old")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = f.Authenticate("alice", "new")
+	assert.NoError(t, err)
+	assert.NoError(t, f.ReloadErr())
+
+	// a broken file keeps the previous users and is logged once per version
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	replaceFile(t, path, "broken line with plain text password\n")
+	time.Sleep(100 * time.Millisecond)
+	_, err = f.Authenticate("alice", "new")
+	assert.NoError(t, err)
+	assert.Error(t, f.ReloadErr(), "failed reload must be reported")
+
+	replaceFile(t, path, "another broken line\n")
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, f.Close())
+	assert.Equal(t, 2, strings.Count(logs.String(), "failed to reload htpasswd file"))
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	_, err := NewAuthenticator(config.HTTPServer{})
+	assert.Equal(t, ErrNoCredentials, err)
+
+	_, err = NewAuthenticator(config.HTTPServer{User: "admin", Password: "$argon2id$v=19$m=8192,t=1,p=1$c2FsdHNhbHQ$"})
+	assert.Error(t, err, "malformed hashes are rejected at startup")
+
+	a, err := NewAuthenticator(config.HTTPServer{User: "admin", Password: "secret"})
+	require.NoError(t, err)
+	_, err = a.Authenticate("admin", "secret")
+	assert.NoError(t, err)
+}
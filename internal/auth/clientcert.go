@@ -0,0 +1,22 @@
+package auth
+
+import "crypto/x509"
+
+// ClientCertPrincipal returns the principal authenticated by a verified client certificate.
+// It is named after the subject common name or, if the certificate has none, its first
+// DNS name, URI or email address SAN, so RBAC subjects and admins can refer to it.
+func ClientCertPrincipal(cert *x509.Certificate, admins Admins) (Principal, bool) {
+	name := cert.Subject.CommonName
+	switch {
+	case name != "":
+	case len(cert.DNSNames) > 0:
+		name = cert.DNSNames[0]
+	case len(cert.URIs) > 0:
+		name = cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		name = cert.EmailAddresses[0]
+	default:
+		return Principal{}, false
+	}
+	return Principal{Name: name, Method: "mtls", Scopes: admins.Scopes(name)}, true
+}
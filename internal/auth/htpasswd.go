@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HtpasswdFile authenticates users listed in an htpasswd-style file with one "user:hash" per line.
+// Hashes are bcrypt ($2a$, $2b$, $2y$) or argon2id ($argon2id$), blank lines and lines
+// starting with # are ignored. The file is reloaded when it changes.
+type HtpasswdFile struct {
+	path   string
+	admins Admins
+
+	mu      sync.RWMutex
+	users   map[string]string
+	modTime time.Time
+	size    int64
+	// reloadErr is the error of the last failed reload in the background, cleared by a successful one
+	reloadErr error
+	// failedModTime and failedSize identify the file version that failed to reload,
+	// it isn't read again until it changes, so the error is logged once
+	failedModTime time.Time
+	failedSize    int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewHtpasswdFile loads the file and checks it for changes every reloadInterval,
+// a zero interval disables reloading.
+func NewHtpasswdFile(path string, reloadInterval time.Duration, admins Admins) (*HtpasswdFile, error) {
+	f := &HtpasswdFile{
+		path:   path,
+		admins: admins,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	if reloadInterval > 0 {
+		go f.watch(reloadInterval)
+	} else {
+		close(f.done)
+	}
+	return f, nil
+}
+
+func (f *HtpasswdFile) Authenticate(username, password string) (Principal, error) {
+	f.mu.RLock()
+	hash, ok := f.users[username]
+	f.mu.RUnlock()
+
+	if !ok {
+		spendHashTime(password)
+		return Principal{}, ErrInvalidCredentials
+	}
+	if !verifyPassword(hash, password) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Name: username, Method: "basic", Scopes: f.admins.Scopes(username)}, nil
+}
+
+// Reload reads the file again. On error the previously loaded users are kept.
+func (f *HtpasswdFile) Reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("stat htpasswd file: %w", err)
+	}
+	users, err := readHtpasswd(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users = users
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.reloadErr = nil
+	return nil
+}
+
+// ReloadErr returns the error of the last reload after the file changed, or nil if it succeeded.
+func (f *HtpasswdFile) ReloadErr() error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.reloadErr
+}
+
+// Close stops watching the file.
+func (f *HtpasswdFile) Close() error {
+	select {
+	case <-f.stop:
+	default:
+		close(f.stop)
+	}
+	<-f.done
+	return nil
+}
+
+func (f *HtpasswdFile) watch(interval time.Duration) {
+	defer close(f.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(f.path)
+			if err != nil || !f.changed(info) {
+				continue
+			}
+			if err := f.Reload(); err != nil {
+				f.mu.Lock()
+				f.reloadErr = err
+				f.failedModTime, f.failedSize = info.ModTime(), info.Size()
+				f.mu.Unlock()
+				slog.Error("failed to reload htpasswd file, keeping previous users", slog.Any("error", err))
+				continue
+			}
+			slog.Info("reloaded htpasswd file", slog.String("path", f.path))
+		}
+	}
+}
+
+// changed tells whether the file differs from the loaded version and the one that failed to reload.
+func (f *HtpasswdFile) changed(info os.FileInfo) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	loaded := info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	failed := f.reloadErr != nil && info.ModTime().Equal(f.failedModTime) && info.Size() == f.failedSize
+	return !loaded && !failed
+}
+
+func readHtpasswd(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open htpasswd file: %w", err)
+	}
+	defer file.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(text, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("htpasswd line %d: want user:hash", line)
+		}
+		if !isHash(hash) {
+			return nil, fmt.Errorf("htpasswd line %d: password of %q is not a bcrypt or argon2id hash", line, username)
+		}
+		if err := checkHash(hash); err != nil {
+			return nil, fmt.Errorf("htpasswd line %d: password of %q: %w", line, username, err)
+		}
+		users[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read htpasswd file: %w", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var ErrUnknownKey = errors.New("token is signed with an unknown key")
+
+// errUnsupportedKey marks keys the verifier can't use, they are skipped instead of
+// failing the whole set.
+var errUnsupportedKey = errors.New("unsupported key")
+
+// jwk is a single JSON Web Key, only public keys used for signatures are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type publicKey struct {
+	key crypto.PublicKey
+	// alg restricts the signing algorithm if the JWK sets it.
+	alg string
+}
+
+// JWKS is a JSON Web Key Set loaded from a file or fetched from a URL.
+// It is refreshed every refreshInterval and when a token refers to an unknown key,
+// but not more often than once per minRefreshInterval.
+type JWKS struct {
+	load               func() ([]byte, error)
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	// refreshes makes concurrent callers share a single load
+	refreshes singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]publicKey
+	fetchedAt time.Time
+}
+
+// NewJWKSFile loads the key set from the file at path.
+func NewJWKSFile(path string, refreshInterval time.Duration) (*JWKS, error) {
+	return newJWKS(func() ([]byte, error) { return os.ReadFile(path) }, refreshInterval)
+}
+
+// NewJWKSURL fetches the key set from url with client.
+func NewJWKSURL(url string, client *http.Client, refreshInterval time.Duration) (*JWKS, error) {
+	return newJWKS(func() ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}, refreshInterval)
+}
+
+func newJWKS(load func() ([]byte, error), refreshInterval time.Duration) (*JWKS, error) {
+	s := &JWKS{load: load, refreshInterval: refreshInterval, minRefreshInterval: 10 * time.Second}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Key returns the key with the kid, an empty kid matches the only key of the set.
+// The lock isn't held while the set is loaded, so a slow JWKS endpoint only delays
+// callers waiting for the refresh.
+func (s *JWKS) Key(kid string) (publicKey, error) {
+	s.mu.Lock()
+	key, ok := s.lookup(kid)
+	stale := s.refreshInterval > 0 && time.Since(s.fetchedAt) > s.refreshInterval
+	due := (!ok || stale) && time.Since(s.fetchedAt) > s.minRefreshInterval
+	s.mu.Unlock()
+
+	if due {
+		if err := s.refresh(); err != nil {
+			slog.Error("failed to refresh JWKS, keeping previous keys", slog.Any("error", err))
+		} else {
+			s.mu.Lock()
+			key, ok = s.lookup(kid)
+			s.mu.Unlock()
+		}
+	}
+	if !ok {
+		return publicKey{}, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (s *JWKS) lookup(kid string) (publicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh loads the key set, callers arriving while a load is in flight wait for its result.
+func (s *JWKS) refresh() error {
+	_, err, _ := s.refreshes.Do("", func() (any, error) {
+		data, err := s.load()
+		var keys map[string]publicKey
+		if err == nil {
+			keys, err = parseJWKS(data)
+		} else {
+			err = fmt.Errorf("load JWKS: %w", err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// failed attempts count too, so an unreachable JWKS isn't requested on every token
+		s.fetchedAt = time.Now()
+		if err != nil {
+			return nil, err
+		}
+		s.keys = keys
+		return nil, nil
+	})
+	return err
+}
+
+// parseJWKS keeps the keys usable for token signatures, other keys are skipped:
+// unsupported ones at debug level, malformed ones with a warning.
+func parseJWKS(data []byte) (map[string]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		switch {
+		case errors.Is(err, errUnsupportedKey):
+			slog.Debug("skipping JWK", slog.String("kid", k.Kid), slog.Any("reason", err))
+		case err != nil:
+			slog.Warn("skipping malformed JWK", slog.String("kid", k.Kid), slog.Any("error", err))
+		default:
+			keys[k.Kid] = publicKey{key: key, alg: k.Alg}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, fmt.Errorf("%w: use %q", errUnsupportedKey, k.Use)
+	}
+	if k.Alg != "" && !slices.Contains(jwtAlgorithms, k.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %q", errUnsupportedKey, k.Alg)
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: key type %q", errUnsupportedKey, k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"homework/internal/config"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms accepted in JWTs.
+var jwtAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+var (
+	ErrJWKSNotConfigured = errors.New("JWT authentication requires a JWKS file or URL")
+	ErrJWTIssuerAudience = errors.New("JWT authentication requires issuer and audience")
+)
+
+// TokenVerifier checks bearer tokens.
+type TokenVerifier interface {
+	// VerifyToken returns an error wrapping ErrInvalidCredentials for invalid tokens.
+	VerifyToken(token string) (Principal, error)
+}
+
+// JWTVerifier verifies JWTs signed with keys from a JWKS and checks their exp, nbf, iss and aud claims.
+type JWTVerifier struct {
+	keys          *JWKS
+	parser        *jwt.Parser
+	scopeClaim    string
+	defaultScopes []Scope
+}
+
+// NewJWTVerifier loads the JWKS from the file or URL in the config,
+// the URL is fetched with client or http.DefaultClient if it is nil.
+func NewJWTVerifier(cfg config.JWT, client *http.Client) (*JWTVerifier, error) {
+	if cfg.JWKSFile == "" && cfg.JWKSURL == "" {
+		return nil, ErrJWKSNotConfigured
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, ErrJWTIssuerAudience
+	}
+	scopeClaim := cfg.ScopeClaim
+	if scopeClaim == "" {
+		scopeClaim = "scope"
+	}
+	defaultScopes := make([]Scope, 0, len(cfg.DefaultScopes))
+	for _, name := range cfg.DefaultScopes {
+		scope := Scope(name)
+		if !validScope(scope) {
+			return nil, fmt.Errorf("JWT default scope %q: %w", name, ErrInvalidScope)
+		}
+		defaultScopes = append(defaultScopes, scope)
+	}
+
+	var (
+		keys *JWKS
+		err  error
+	)
+	if cfg.JWKSFile != "" {
+		keys, err = NewJWKSFile(cfg.JWKSFile, cfg.JWKSRefreshInterval)
+	} else {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		keys, err = NewJWKSURL(cfg.JWKSURL, client, cfg.JWKSRefreshInterval)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &JWTVerifier{
+		keys: keys,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(jwtAlgorithms),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(cfg.Leeway),
+		),
+		scopeClaim:    scopeClaim,
+		defaultScopes: defaultScopes,
+	}, nil
+}
+
+// VerifyToken returns the principal named after the sub claim. Scopes are taken from the
+// configured scope claim, tokens without scopes get the default ones.
+// The principal carries all claims of the token.
+func (v *JWTVerifier) VerifyToken(token string) (Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, v.keyFunc)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	return Principal{
+		Name:   subject,
+		Method: "jwt",
+		Scopes: v.tokenScopes(claims),
+		Roles:  tokenRoles(claims),
+		Claims: claims,
+	}, nil
+}
+
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := v.keys.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && key.alg != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q is for %s, token is signed with %s", kid, key.alg, token.Method.Alg())
+	}
+	return key.key, nil
+}
+
+// tokenScopes reads the scope claim, a space-separated string or a list of strings.
+// Unknown scopes, e.g. openid, are ignored.
+func (v *JWTVerifier) tokenScopes(claims jwt.MapClaims) []Scope {
+	var names []string
+	switch raw := claims[v.scopeClaim].(type) {
+	case string:
+		names = strings.Fields(raw)
+	case []any:
+		for _, name := range raw {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	var scopes []Scope
+	for _, name := range names {
+		if scope := Scope(name); validScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 && len(v.defaultScopes) > 0 {
+		return v.defaultScopes
+	}
+	return scopes
+}
+
+// tokenRoles reads the roles claim, a list of strings.
+func tokenRoles(claims jwt.MapClaims) []string {
+	raw, _ := claims["roles"].([]any)
+	var roles []string
+	for _, r := range raw {
+		if role, ok := r.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// IsJWT tells whether a bearer token looks like a JWT rather than an API key.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ecdsa   *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return testKeys{rsa: rsaKey, ecdsa: ecKey, ed25519: edKey}
+}
+
+func (k testKeys) jwks(t *testing.T) []byte {
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig",
+			"n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": b64(k.ecdsa.X.FillBytes(make([]byte, 32))), "y": b64(k.ecdsa.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(k.ed25519.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(k.rsa.N.Bytes()), "e": "AQAB"},
+	}})
+	require.NoError(t, err)
+	return data
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "alice",
+		"iss":   "https://sso.example.com",
+		"aud":   "deviced",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "devices:read openid",
+		"roles": []string{"operator"},
+	}
+}
+
+func withClaim(key string, value any) jwt.MapClaims {
+	claims := validClaims()
+	if value == nil {
+		delete(claims, key)
+	} else {
+		claims[key] = value
+	}
+	return claims
+}
+
+func TestJWTVerifier(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, keys.jwks(t), 0o600))
+
+	verifier, err := NewJWTVerifier(config.JWT{
+		JWKSFile: path,
+		Issuer:   "https://sso.example.com",
+		Audience: "deviced",
+	}, nil)
+	require.NoError(t, err)
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "RS256", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims()), valid: true},
+		{name: "ES256", token: sign(t, jwt.SigningMethodES256, "ec", keys.ecdsa, validClaims()), valid: true},
+		{name: "EdDSA", token: sign(t, jwt.SigningMethodEdDSA, "ed", keys.ed25519, validClaims()), valid: true},
+		{name: "HS256 is rejected", token: hmacToken},
+		{name: "Algorithm of the key", token: sign(t, jwt.SigningMethodRS512, "rsa", keys.rsa, validClaims())},
+		{name: "Unknown kid", token: sign(t, jwt.SigningMethodRS256, "other", keys.rsa, validClaims())},
+		{name: "Encryption key", token: sign(t, jwt.SigningMethodRS256, "enc", keys.rsa, validClaims())},
+		{name: "Wrong signature", token: sign(t, jwt.SigningMethodES256, "ec", mustECKey(t), validClaims())},
+		{name: "Expired", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim("exp", time.Now().Add(-time.Hour).Unix()))},
+		{name: "No exp", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim("exp", nil))},
+		{name: "Not yet valid", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim("nbf", time.Now().Add(time.Hour).Unix()))},
+		{name: "Wrong issuer", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim("iss", "https://evil.example.com"))},
+		{name: "Wrong audience", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim("aud", "billing"))},
+		{name: "Audience list", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim("aud", []string{"billing", "deviced"})), valid: true},
+		{name: "No subject", token: sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim("sub", nil))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifier.VerifyToken(tt.token)
+			if !tt.valid {
+				assert.True(t, errors.Is(err, ErrInvalidCredentials), "want invalid credentials, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "alice", principal.Name)
+			assert.Equal(t, "jwt", principal.Method)
+			assert.Equal(t, []Scope{ScopeDevicesRead}, principal.Scopes)
+			assert.Equal(t, []string{"operator"}, principal.Roles)
+			assert.Equal(t, "https://sso.example.com", principal.Claims["iss"])
+		})
+	}
+}
+
+func TestJWTVerifier_Scopes(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, keys.jwks(t), 0o600))
+	cfg := config.JWT{JWKSFile: path, Issuer: "https://sso.example.com", Audience: "deviced"}
+
+	tests := []struct {
+		name          string
+		scopeClaim    string
+		defaultScopes []string
+		claims        jwt.MapClaims
+		expected      []Scope
+	}{
+		{name: "No scope", claims: withClaim("scope", nil)},
+		{name: "Only unknown scopes", claims: withClaim("scope", "openid profile")},
+		{name: "Default scopes", defaultScopes: []string{"devices:read"}, claims: withClaim("scope", nil),
+			expected: []Scope{ScopeDevicesRead}},
+		{name: "Scopes of the token win over defaults", defaultScopes: []string{"devices:read"},
+			claims: withClaim("scope", "devices:write"), expected: []Scope{ScopeDevicesWrite}},
+		{name: "Custom claim", scopeClaim: "scp", claims: withClaim("scp", "devices:read devices:write"),
+			expected: []Scope{ScopeDevicesRead, ScopeDevicesWrite}},
+		{name: "Custom claim ignores scope", scopeClaim: "scp", claims: validClaims()},
+		{name: "List of scopes", scopeClaim: "scp", claims: withClaim("scp", []string{"admin", "openid"}),
+			expected: []Scope{ScopeAdmin}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.ScopeClaim, cfg.DefaultScopes = tt.scopeClaim, tt.defaultScopes
+			verifier, err := NewJWTVerifier(cfg, nil)
+			require.NoError(t, err)
+
+			principal, err := verifier.VerifyToken(sign(t, jwt.SigningMethodEdDSA, "ed", keys.ed25519, tt.claims))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, principal.Scopes)
+		})
+	}
+
+	cfg.DefaultScopes = []string{"devices:delete"}
+	_, err := NewJWTVerifier(cfg, nil)
+	assert.ErrorIs(t, err, ErrInvalidScope)
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestJWTVerifier_JWKSURL(t *testing.T) {
+	keys := newTestKeys(t)
+	jwks := keys.jwks(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(jwks)
+	}))
+	defer srv.Close()
+
+	verifier, err := NewJWTVerifier(config.JWT{
+		JWKSURL:  srv.URL,
+		Issuer:   "https://sso.example.com",
+		Audience: "deviced",
+	}, srv.Client())
+	require.NoError(t, err)
+
+	_, err = verifier.VerifyToken(sign(t, jwt.SigningMethodEdDSA, "ed", keys.ed25519, validClaims()))
+	assert.NoError(t, err)
+
+	// unknown keys don't make every request hit the JWKS endpoint
+	for i := 0; i < 5; i++ {
+		_, err = verifier.VerifyToken(sign(t, jwt.SigningMethodRS256, "rotated", keys.rsa, validClaims()))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestNewJWTVerifier_Invalid(t *testing.T) {
+	_, err := NewJWTVerifier(config.JWT{}, nil)
+	assert.Equal(t, ErrJWKSNotConfigured, err)
+
+	_, err = NewJWTVerifier(config.JWT{JWKSFile: "jwks.json"}, nil)
+	assert.Equal(t, ErrJWTIssuerAudience, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys":[{"kty":"EC","kid":"ec","crv":"P-384","x":"AA","y":"AA"}]}`), 0o600))
+	_, err = NewJWTVerifier(config.JWT{JWKSFile: path, Issuer: "iss", Audience: "aud"}, nil)
+	assert.Error(t, err)
+}
+
+func TestJWKS_RefreshOutsideLock(t *testing.T) {
+	jwks := newTestKeys(t).jwks(t)
+	var loads atomic.Int32
+	release := make(chan struct{})
+	s, err := newJWKS(func() ([]byte, error) {
+		if loads.Add(1) > 1 {
+			<-release
+		}
+		return jwks, nil
+	}, 0)
+	require.NoError(t, err)
+	s.fetchedAt = time.Time{}
+
+	// lookups of unknown keys wait for a single shared load
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Key("rotated")
+			assert.ErrorIs(t, err, ErrUnknownKey)
+		}()
+	}
+	require.Eventually(t, func() bool { return loads.Load() == 2 }, time.Second, time.Millisecond)
+
+	// known keys don't wait for the load
+	_, err = s.Key("ed")
+	assert.NoError(t, err)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), loads.Load())
+}
+
+func TestParseJWKS(t *testing.T) {
+	ed := base64.RawURLEncoding.EncodeToString(newTestKeys(t).ed25519.Public().(ed25519.PublicKey))
+	edKey := `{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"` + ed + `"}`
+	tests := []struct {
+		name  string
+		keys  string
+		kids  []string
+		error bool
+	}{
+		{name: "Supported key", keys: edKey, kids: []string{"ed"}},
+		{name: "Unsupported key type", keys: edKey + `,{"kty":"oct","kid":"hmac","k":"c2VjcmV0"}`, kids: []string{"ed"}},
+		{name: "Unsupported curve", keys: edKey + `,{"kty":"EC","kid":"p384","crv":"P-384","x":"AA","y":"AA"}`, kids: []string{"ed"}},
+		{name: "Unsupported algorithm", keys: edKey + `,{"kty":"OKP","kid":"hs","alg":"HS256","crv":"Ed25519","x":"` + ed + `"}`, kids: []string{"ed"}},
+		{name: "Encryption key", keys: edKey + `,{"kty":"OKP","kid":"enc","use":"enc","crv":"Ed25519","x":"` + ed + `"}`, kids: []string{"ed"}},
+		{name: "Malformed key", keys: edKey + `,{"kty":"OKP","kid":"bad","crv":"Ed25519","x":"AA"}`, kids: []string{"ed"}},
+		{name: "No usable keys", keys: `{"kty":"oct","kid":"hmac","k":"c2VjcmV0"}`, error: true},
+		{name: "Empty set", keys: ``, error: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseJWKS([]byte(`{"keys":[` + tt.keys + `]}`))
+			if tt.error {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			kids := make([]string, 0, len(keys))
+			for kid := range keys {
+				kids = append(kids, kid)
+			}
+			assert.ElementsMatch(t, tt.kids, kids)
+		})
+	}
+}
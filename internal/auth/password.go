@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2idPrefix = "$argon2id$"
+	// argon2idMaxMemory caps the memory of a hash in KiB, so a hash can't make verification allocate unbounded memory
+	argon2idMaxMemory  = 1 << 20
+	argon2idMinSaltLen = 8
+	argon2idMinKeyLen  = 16
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// isHash tells whether s is a password hash in one of the supported formats.
+func isHash(s string) bool {
+	return isBcrypt(s) || strings.HasPrefix(s, argon2idPrefix)
+}
+
+func isBcrypt(s string) bool {
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
+
+// verifyPassword checks password against a bcrypt or argon2id hash, or a plain text password
+// if stored is not a hash. Malformed hashes never match.
+func verifyPassword(stored, password string) bool {
+	switch {
+	case isBcrypt(stored):
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	case strings.HasPrefix(stored, argon2idPrefix):
+		ok, err := verifyArgon2id(stored, password)
+		return err == nil && ok
+	default:
+		return constantTimeEqual(stored, password)
+	}
+}
+
+// spendHashTime verifies password against a dummy hash,
+// so requests for unknown users take as long as for known ones.
+func spendHashTime(password string) {
+	dummyHashOnce.Do(func() {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+		dummyHash = string(hash)
+	})
+	verifyPassword(dummyHash, password)
+}
+
+// constantTimeEqual compares digests, so neither contents nor lengths leak through timing.
+func constantTimeEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// argon2idParams is a parsed hash in the PHC string format.
+type argon2idParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// parseArgon2id parses and validates a hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=4$<base64 salt>$<base64 key>
+func parseArgon2id(hash string) (argon2idParams, error) {
+	var h argon2idParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return h, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return h, fmt.Errorf("malformed argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return h, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil {
+		return h, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	if h.time < 1 || h.threads < 1 {
+		return h, fmt.Errorf("invalid argon2id parameters t=%d, p=%d", h.time, h.threads)
+	}
+	if h.memory > argon2idMaxMemory {
+		return h, fmt.Errorf("argon2id memory %d KiB exceeds the limit of %d KiB", h.memory, argon2idMaxMemory)
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return h, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	if len(h.salt) < argon2idMinSaltLen {
+		return h, fmt.Errorf("argon2id salt is shorter than %d bytes", argon2idMinSaltLen)
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return h, fmt.Errorf("malformed argon2id key: %w", err)
+	}
+	if len(h.key) < argon2idMinKeyLen {
+		return h, fmt.Errorf("argon2id key is shorter than %d bytes", argon2idMinKeyLen)
+	}
+	return h, nil
+}
+
+// verifyArgon2id checks password against a hash in the PHC string format,
+// malformed hashes return an error.
+func verifyArgon2id(hash, password string) (bool, error) {
+	h, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	actual := argon2.IDKey([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+	return subtle.ConstantTimeCompare(actual, h.key) == 1, nil
+}
+
+// checkHash validates a bcrypt or argon2id hash, so malformed ones are rejected when loaded.
+func checkHash(hash string) error {
+	if isBcrypt(hash) {
+		_, err := bcrypt.Cost([]byte(hash))
+		return err
+	}
+	_, err := parseArgon2id(hash)
+	return err
+}
@@ -2,20 +2,39 @@ package client
 
 import (
 	"homework/internal/config"
+	"homework/internal/redact"
 	"homework/internal/roundtripper"
 	"net/http"
 )
 
-func NewClient(cfg *config.Config, customRoundTrippers ...http.RoundTripper) *http.Client {
-	transport := http.DefaultTransport
+// NewClient returns a client sending requests through custom middlewares, the first one being
+// the innermost, that wrap http.DefaultTransport. They are wrapped by the circuit breaker,
+// retries, logging, tracing and request ID propagation, so they see every retry attempt
+// and their errors count as breaker failures.
+func NewClient(cfg *config.Config, customMiddlewares ...roundtripper.Middleware) *http.Client {
+	return NewClientWithBreakers(cfg, roundtripper.NewHostBreakers(cfg.HttpClient.Breaker), customMiddlewares...)
+}
 
-	for _, rt := range customRoundTrippers {
-		transport = roundtripper.UserRoundTripper{Transport: transport, UserRoundTrip: rt}
-	}
+// NewClientWithBreakers is like NewClient, but keeps the circuit breakers in breakers,
+// e.g. to report their state in readiness checks.
+func NewClientWithBreakers(cfg *config.Config, breakers *roundtripper.HostBreakers, customMiddlewares ...roundtripper.Middleware) *http.Client {
+	transport := roundtripper.Chain(http.DefaultTransport, customMiddlewares...)
 
-	transport = roundtripper.BreakerRoundTripper{Cb: roundtripper.CreateCb(), Next: transport}
+	transport = roundtripper.BreakerRoundTripper{Breakers: breakers, Next: transport}
+
+	retry := cfg.HttpClient.Retry
+	transport = roundtripper.RetryRoundTripper{
+		Next:        transport,
+		MaxAttempts: retry.MaxAttempts,
+		BaseDelay:   retry.BaseDelay,
+		MaxDelay:    retry.MaxDelay,
+		Budget:      roundtripper.NewRetryBudget(retry.BudgetRatio, retry.BudgetMax),
+	}
 
-	transport = roundtripper.LoggingRoundTripper{Next: transport}
+	redaction := redact.PolicyFromConfig(cfg.Redaction)
+	transport = roundtripper.LoggingRoundTripper{Next: transport, Redaction: redaction}
+	transport = roundtripper.TracingRoundTripper{Next: transport, Redaction: redaction}
+	transport = roundtripper.RequestIDRoundTripper{Next: transport}
 	return &http.Client{
 		Transport: transport,
 		Timeout:   cfg.HttpClient.Timeout,
@@ -2,16 +2,28 @@ package client_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/sony/gobreaker"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	client2 "homework/internal/client"
 	"homework/internal/config"
+	"homework/internal/middleware"
 	"homework/internal/roundtripper"
+	"homework/internal/tracing"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -40,11 +52,65 @@ func TestLoggingRoundTripper(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-	expectedOutgoingLog := fmt.Sprintf("Outgoing Request: GET %s map[]", testServer.URL)
-	expectedIncomingLog := fmt.Sprintf("Incoming Response")
+	expectedLog := fmt.Sprintf("INFO incoming response method=GET url=%s status=200 latency=", testServer.URL)
 
-	assert.Contains(t, logBuffer.String(), expectedOutgoingLog)
-	assert.Contains(t, logBuffer.String(), expectedIncomingLog)
+	assert.Contains(t, logBuffer.String(), expectedLog)
+}
+
+func TestLoggingRoundTripper_Redaction(t *testing.T) {
+	var logBuffer bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&logBuffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	client := &http.Client{Transport: roundtripper.LoggingRoundTripper{Next: http.DefaultTransport, Logger: l}}
+	req, err := http.NewRequest("GET", testServer.URL+"?access_token=t0ken", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer t0ken")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Error making HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	assert.NotContains(t, logBuffer.String(), "t0ken")
+	assert.NotContains(t, logBuffer.String(), "s3cr3t")
+	assert.Contains(t, logBuffer.String(), "[REDACTED]")
+}
+
+func TestNewClient_ForwardsRequestID(t *testing.T) {
+	forwarded := make(chan string, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Get("X-Request-ID")
+	}))
+	defer downstream.Close()
+
+	client := client2.NewClient(&config.Config{})
+	upstream := middleware.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), "POST", downstream.URL, nil)
+		assert.NoError(t, err)
+		resp, err := client.Do(req)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}))
+
+	req := httptest.NewRequest("PUT", "/v1/devices/1234", nil)
+	req.Header.Set("X-Request-ID", "update-42")
+	upstream.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "update-42", <-forwarded)
+
+	// requests made outside of a server request are sent without an ID
+	resp, err := client.Get(downstream.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	assert.Equal(t, "", <-forwarded)
 }
 
 func TestBreakerRoundTripper(t *testing.T) {
@@ -59,8 +125,7 @@ func TestBreakerRoundTripper(t *testing.T) {
 	}))
 	defer testServer.Close()
 
-	cb := roundtripper.CreateCb()
-	breakerRoundTripper := roundtripper.BreakerRoundTripper{Cb: cb, Next: http.DefaultTransport}
+	breakerRoundTripper := roundtripper.BreakerRoundTripper{Breakers: roundtripper.NewHostBreakers(config.Breaker{}), Next: http.DefaultTransport}
 
 	client := &http.Client{
 		Timeout:   time.Second * 10,
@@ -72,15 +137,11 @@ func TestBreakerRoundTripper(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-	// Test a failing request to trigger the circuit breaker
+	// Test a failing request to trigger the circuit breaker, the response is still returned
 	resp, err = client.Get(testServer.URL + "/server-error")
-	assert.NotNil(t, err)
-
-	// Extract the error message without the appended URL
-	expectedErrorMessage := "Server error: 500 Internal Server Error"
-	actualErrorMessage := err.Error()
-	assert.Contains(t, actualErrorMessage, expectedErrorMessage)
-	assert.Nil(t, resp)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	resp.Body.Close()
 
 	time.Sleep(6 * time.Second)
 
@@ -89,7 +150,77 @@ func TestBreakerRoundTripper(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
-func TestUserRoundTripper(t *testing.T) {
+func TestBreakerRoundTripper_PerHost(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	breakers := roundtripper.NewHostBreakers(config.Breaker{
+		ConsecutiveFailures: 2,
+		OpenTimeout:         time.Minute,
+		FailureStatuses:     []int{http.StatusTooManyRequests},
+	})
+	client := &http.Client{Transport: roundtripper.BreakerRoundTripper{Breakers: breakers, Next: http.DefaultTransport}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(failing.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	}
+	_, err := client.Get(failing.URL)
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+
+	resp, err := client.Get(healthy.URL)
+	require.NoError(t, err, "a failing host must not block requests to other hosts")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	failingHost := strings.TrimPrefix(failing.URL, "http://")
+	assert.Equal(t, gobreaker.StateOpen, breakers.Get(failingHost).State())
+	assert.Equal(t, failingHost, breakers.Get(failingHost).Name())
+}
+
+func TestHostBreakers_EvictIdle(t *testing.T) {
+	breakers := roundtripper.NewHostBreakers(config.Breaker{
+		ConsecutiveFailures: 1,
+		OpenTimeout:         time.Minute,
+		IdleTimeout:         20 * time.Millisecond,
+	})
+	idle := breakers.Get("idle.example.com")
+	open := breakers.Get("open.example.com")
+	open.Execute(func() (any, error) { return nil, errors.New("failed") })
+	require.Equal(t, gobreaker.StateOpen, open.State())
+
+	time.Sleep(30 * time.Millisecond)
+	breakers.Get("other.example.com")
+
+	assert.NotSame(t, idle, breakers.Get("idle.example.com"), "an idle closed breaker must be dropped")
+	assert.Same(t, open, breakers.Get("open.example.com"), "an open breaker must be kept")
+}
+
+func TestNewBreaker_FailureRatio(t *testing.T) {
+	cb := roundtripper.NewBreaker("ratio", config.Breaker{ConsecutiveFailures: 100, FailureRatio: 0.5, MinRequests: 4})
+	fail := func() (any, error) { return nil, errors.New("failed") }
+	succeed := func() (any, error) { return nil, nil }
+
+	for _, req := range []func() (any, error){fail, succeed, fail} {
+		cb.Execute(req)
+	}
+	assert.Equal(t, gobreaker.StateClosed, cb.State(), "too few requests to trip")
+
+	cb.Execute(succeed)
+	assert.Equal(t, gobreaker.StateClosed, cb.State())
+	cb.Execute(fail)
+	assert.Equal(t, gobreaker.StateOpen, cb.State(), "3 of 5 requests failed")
+}
+
+func TestNewClient_Middlewares(t *testing.T) {
 	currentDir, err := os.Getwd()
 	if err != nil {
 		t.Fatalf("Error getting current directory: %v", err)
@@ -99,38 +230,227 @@ func TestUserRoundTripper(t *testing.T) {
 
 	cfg := config.LoadConfig(configPath)
 
-	fakeUserRoundTripper := &FakeRoundTripper{}
-
+	var attempts int
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "outer,inner", r.Header.Get("X-Middlewares"))
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer testServer.Close()
 
-	client := client2.NewClient(cfg, fakeUserRoundTripper)
+	var calls []string
+	middleware := func(name string) roundtripper.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundtripper.Func(func(r *http.Request) (*http.Response, error) {
+				r = r.Clone(r.Context())
+				r.Header.Set("X-Middlewares", strings.TrimPrefix(r.Header.Get("X-Middlewares")+","+name, ","))
+				resp, err := next.RoundTrip(r)
+				if err == nil {
+					calls = append(calls, fmt.Sprintf("%s %d", name, resp.StatusCode))
+				}
+				return resp, err
+			})
+		}
+	}
+
+	client := client2.NewClient(cfg, middleware("inner"), middleware("outer"))
 
 	resp, err := client.Get(testServer.URL)
-	assert.Nil(t, err)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// middlewares delegate to the transport and see every retry attempt, the first one is the innermost
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, []string{"inner 503", "outer 503", "inner 200", "outer 200"}, calls)
+}
 
-	// Ensure that the fake UserRoundTripper was called
-	assert.True(t, fakeUserRoundTripper.Called)
+func TestNewClient_RetryAfter(t *testing.T) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Error getting current directory: %v", err)
+	}
+
+	configPath := filepath.Join(currentDir, "..", "..", "config", "local.yaml")
+
+	cfg := config.LoadConfig(configPath)
+
+	var attempts []time.Time
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, time.Now())
+		if len(attempts) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
 
-	assert.NotNil(t, resp)
+	resp, err := client2.NewClient(cfg).Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the breaker passes the 503 on, so the retry waits as long as the server asked
+	require.Len(t, attempts, 2)
+	assert.GreaterOrEqual(t, attempts[1].Sub(attempts[0]), time.Second)
 }
 
-// FakeRoundTripper is a fake implementation of http.RoundTripper for testing
-type FakeRoundTripper struct {
-	Called  bool
-	Request *http.Request
+func TestNewClient_PropagatesTraceContext(t *testing.T) {
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	forwarded := make(chan string, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Get("traceparent")
+	}))
+	defer downstream.Close()
+
+	client := client2.NewClient(&config.Config{})
+	upstream := tracing.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), "GET", downstream.URL, nil)
+		assert.NoError(t, err)
+		resp, err := client.Do(req)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}))
+
+	req := httptest.NewRequest("PUT", "/v1/devices/1234", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	upstream.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := <-forwarded
+	assert.True(t, strings.HasPrefix(got, "00-4bf92f3577b34da6a3ce929d0e0e4736-"), "trace ID must be kept, got %q", got)
+	assert.NotContains(t, got, "00f067aa0ba902b7", "the client span must be the parent of the downstream request")
 }
 
-func (f *FakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	f.Called = true
-	f.Request = req
+func TestRetryRoundTripper(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		header       http.Header
+		body         string
+		statuses     []int
+		retryAfter   string
+		wantStatus   int
+		wantAttempts int
+	}{
+		{name: "retries GET on 503", method: "GET", statuses: []int{503, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "gives up after max attempts", method: "GET", statuses: []int{500, 502, 504}, wantStatus: 504, wantAttempts: 3},
+		{name: "retries PUT with body", method: "PUT", body: `{"model":"hp"}`, statuses: []int{502, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "does not retry POST", method: "POST", body: `{"model":"hp"}`, statuses: []int{503, 200}, wantStatus: 503, wantAttempts: 1},
+		{
+			name: "retries POST with idempotency key", method: "POST", body: `{"model":"hp"}`,
+			header:   http.Header{"Idempotency-Key": {"create-1234"}},
+			statuses: []int{503, 201}, wantStatus: 201, wantAttempts: 2,
+		},
+		{name: "does not retry 501", method: "GET", statuses: []int{501, 200}, wantStatus: 501, wantAttempts: 1},
+		{name: "does not retry 4xx", method: "GET", statuses: []int{404, 200}, wantStatus: 404, wantAttempts: 1},
+		{name: "honours Retry-After", method: "GET", statuses: []int{429, 200}, retryAfter: "0", wantStatus: 200, wantAttempts: 2},
+		{name: "Retry-After longer than max delay", method: "GET", statuses: []int{429, 200}, retryAfter: "120", wantStatus: 429, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.body, string(body), "the body must be sent with every attempt")
+
+				status := tt.statuses[attempts]
+				attempts++
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: roundtripper.RetryRoundTripper{
+				Next:        http.DefaultTransport,
+				MaxAttempts: 3,
+				BaseDelay:   time.Millisecond,
+				MaxDelay:    10 * time.Millisecond,
+			}}
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestRetryRoundTripper_ConnectionErrors(t *testing.T) {
+	var attempts int
+	rt := roundtripper.RetryRoundTripper{
+		Next: roundtripper.Func(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, errors.New("connection reset by peer")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		MaxAttempts: 3,
+	}
+
+	resp, err := rt.RoundTrip(httptest.NewRequest("GET", "http://devices.local/v1/devices", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, attempts)
+
+	// a canceled request is not retried
+	attempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rt.Next = roundtripper.Func(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, r.Context().Err()
+	})
+	_, err = rt.RoundTrip(httptest.NewRequest("GET", "http://devices.local/v1/devices", nil).WithContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryRoundTripper_Budget(t *testing.T) {
+	var attempts int
+	rt := roundtripper.RetryRoundTripper{
+		Next: roundtripper.Func(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		}),
+		MaxAttempts: 3,
+		Budget:      roundtripper.NewRetryBudget(0.5, 1),
+	}
+
+	// the initial token allows one retry
+	resp, err := rt.RoundTrip(httptest.NewRequest("GET", "http://devices.local/v1/devices", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 2, attempts)
 
-	// Return a dummy response
-	return &http.Response{
-		StatusCode: http.StatusOK,
-		Body:       nil,
-	}, nil
+	// every request earns half a retry
+	attempts = 0
+	rt.RoundTrip(httptest.NewRequest("GET", "http://devices.local/v1/devices", nil))
+	assert.Equal(t, 1, attempts, "the budget is exhausted")
+	attempts = 0
+	rt.RoundTrip(httptest.NewRequest("GET", "http://devices.local/v1/devices", nil))
+	assert.Equal(t, 2, attempts, "two requests earned a retry")
 }
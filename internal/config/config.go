@@ -10,27 +10,209 @@ import (
 
 type Config struct {
 	Env        string `yaml:"env" env-default:"local"`
+	Log        Log    `yaml:"log"`
 	HTTPServer `yaml:"http_server"`
 	HttpClient HttpClient `yaml:"http_client"`
+	Validation Validation `yaml:"validation"`
+	Storage    Storage    `yaml:"storage"`
+	RBAC       RBAC       `yaml:"rbac"`
+	Redaction  Redaction  `yaml:"redaction"`
+	Tracing    Tracing    `yaml:"tracing"`
+	Health     Health     `yaml:"health"`
+}
+
+// Log configures the structured logger, empty values default by Env:
+// local logs text at debug level, dev JSON at debug level and prod JSON at info level.
+type Log struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+	// Format is json or text.
+	Format string `yaml:"format" env:"LOG_FORMAT"`
 }
 
 type HTTPServer struct {
-	Address     string        `yaml:"address" env-default:"localhost:8080"`
+	Address     string        `yaml:"address" env:"HTTP_SERVER_ADDRESS" env-default:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
-	User        string        `yaml:"user" env-required:"true"`
-	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	// ShutdownTimeout bounds how long in-flight requests are drained on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SERVER_SHUTDOWN_TIMEOUT" env-default:"15s"`
+	// DrainDelay is how long the server keeps serving after readiness is flipped to failing,
+	// so load balancers stop routing new requests before connections are closed.
+	DrainDelay time.Duration `yaml:"drain_delay" env:"HTTP_SERVER_DRAIN_DELAY" env-default:"0s"`
+	// User and Password are the only basic auth credentials when HtpasswdFile is not set.
+	// Password is a bcrypt or argon2id hash or plain text.
+	User     string `yaml:"user"`
+	Password string `yaml:"password" env:"HTTP_SERVER_PASSWORD"`
+	// HtpasswdFile lists basic auth users as "user:hash" lines, it is reloaded when it changes.
+	HtpasswdFile           string        `yaml:"htpasswd_file" env:"HTTP_SERVER_HTPASSWD_FILE"`
+	HtpasswdReloadInterval time.Duration `yaml:"htpasswd_reload_interval" env-default:"10s"`
+	// APIKeysFile stores hashed API keys, API key authentication is disabled when it is empty.
+	APIKeysFile string `yaml:"api_keys_file" env:"HTTP_SERVER_API_KEYS_FILE"`
+	JWT         JWT    `yaml:"jwt"`
+	TLS         TLS    `yaml:"tls"`
+	// LegacyRoutes keeps the RPC-style /getDevice, /createDevice, ... routes during migration to /v1/devices.
+	LegacyRoutes bool `yaml:"legacy_routes" env:"HTTP_SERVER_LEGACY_ROUTES" env-default:"false"`
+	// Admins are the basic auth users and client certificate names granted the admin scope,
+	// other users and certificates may only read and write devices.
+	Admins []string `yaml:"admins" env:"HTTP_SERVER_ADMINS"`
+}
+
+// JWT configures verification of bearer JWTs, it is disabled when neither JWKSFile nor JWKSURL is set.
+type JWT struct {
+	JWKSFile            string        `yaml:"jwks_file" env:"JWT_JWKS_FILE"`
+	JWKSURL             string        `yaml:"jwks_url" env:"JWT_JWKS_URL"`
+	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval" env-default:"5m"`
+	Issuer              string        `yaml:"issuer" env:"JWT_ISSUER"`
+	Audience            string        `yaml:"audience" env:"JWT_AUDIENCE"`
+	// Leeway tolerates clock skew when checking exp and nbf.
+	Leeway time.Duration `yaml:"leeway" env-default:"30s"`
+	// ScopeClaim names the claim with the scopes of the token, a space-separated string
+	// or a list of strings, e.g. "scp" for some identity providers.
+	ScopeClaim string `yaml:"scope_claim" env:"JWT_SCOPE_CLAIM" env-default:"scope"`
+	// DefaultScopes are granted to tokens without scopes, such tokens may do nothing when it is empty.
+	DefaultScopes []string `yaml:"default_scopes" env:"JWT_DEFAULT_SCOPES"`
+}
+
+// TLS configures HTTPS, the server serves plain HTTP when CertFile is empty.
+// Certificate, key and client CA files are reloaded when they change, so rotated certificates
+// are picked up without a restart.
+type TLS struct {
+	CertFile string `yaml:"cert_file" env:"HTTP_SERVER_TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"HTTP_SERVER_TLS_KEY_FILE"`
+	// MinVersion is "1.2" or "1.3".
+	MinVersion string `yaml:"min_version" env:"HTTP_SERVER_TLS_MIN_VERSION" env-default:"1.2"`
+	// ClientCAFile is a PEM bundle of CAs verifying client certificates. Clients presenting a valid
+	// certificate are authenticated by its subject, see auth.ClientCertPrincipal.
+	ClientCAFile string `yaml:"client_ca_file" env:"HTTP_SERVER_TLS_CLIENT_CA_FILE"`
+	// RequireClientCert rejects connections without a valid client certificate.
+	RequireClientCert bool          `yaml:"require_client_cert" env:"HTTP_SERVER_TLS_REQUIRE_CLIENT_CERT" env-default:"false"`
+	ReloadInterval    time.Duration `yaml:"reload_interval" env-default:"1m"`
+}
+
+// RBAC configures role-based access control of device operations.
+// Every authenticated caller may do everything when it is disabled.
+type RBAC struct {
+	Enabled bool `yaml:"enabled" env:"RBAC_ENABLED" env-default:"false"`
+	// DefaultRole is given to callers without roles, empty denies them everything.
+	DefaultRole string `yaml:"default_role" env:"RBAC_DEFAULT_ROLE"`
+	// Subjects assigns roles to principals by name, e.g. to basic auth users.
+	Subjects map[string][]string `yaml:"subjects"`
+	// Policies replace the default policies of the viewer, operator and admin roles when set.
+	Policies []Policy `yaml:"policies"`
+}
+
+// Policy allows a role to perform operations, optionally only on devices of some models.
+type Policy struct {
+	Role string `yaml:"role"`
+	// Operations are names like "devices:create", "*" allows all operations.
+	Operations []string `yaml:"operations"`
+	// Models restricts the policy to devices of these models, empty means any model.
+	Models []string `yaml:"models"`
+}
+
+// Storage selects the DeviceStorage backend.
+type Storage struct {
+	// Backend is one of "memory", "file" or "sqlite".
+	Backend string `yaml:"backend" env:"STORAGE_BACKEND" env-default:"memory"`
+	// Path is the data directory of the file backend or the DSN of the sqlite backend.
+	Path string `yaml:"path" env:"STORAGE_PATH"`
+	// CompactInterval is how often the file backend compacts its log.
+	CompactInterval time.Duration `yaml:"compact_interval" env:"STORAGE_COMPACT_INTERVAL" env-default:"5m"`
+}
+
+// Validation holds device validation rules per Device.Model.
+type Validation struct {
+	Models map[string]ModelRules `yaml:"models"`
+}
+
+// ModelRules overrides the default validation of devices of a single model.
+// Zero fields keep the defaults.
+type ModelRules struct {
+	SerialPattern   string `yaml:"serial_pattern"`
+	SerialMinLength int    `yaml:"serial_min_length"`
+	SerialMaxLength int    `yaml:"serial_max_length"`
+	// IPFamilies lists allowed address families: "ipv4", "ipv6".
+	IPFamilies []string `yaml:"ip_families"`
+	// Subnets lists CIDRs the device IP must belong to.
+	Subnets []string `yaml:"subnets"`
+}
+
+// Redaction lists values hidden in request and response logs in addition to the defaults,
+// e.g. the Authorization header and password fields, see package redact.
+type Redaction struct {
+	Headers     []string `yaml:"headers"`
+	QueryParams []string `yaml:"query_params"`
+	// BodyFields are JSON object keys, matched at any depth.
+	BodyFields []string `yaml:"body_fields"`
+}
+
+// Tracing configures OpenTelemetry tracing of requests, service and storage calls.
+// Incoming trace context is propagated to outgoing requests even when spans aren't exported.
+type Tracing struct {
+	// Exporter is "none", "stdout", "file" or "otlp".
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// Endpoint is the host:port of the OTLP/HTTP collector, the exporter default is used if it is empty.
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	// Insecure sends spans to the collector over plain HTTP.
+	Insecure bool `yaml:"insecure" env:"TRACING_INSECURE" env-default:"false"`
+	// FilePath is where the file exporter appends spans as JSON.
+	FilePath string `yaml:"file_path" env:"TRACING_FILE_PATH"`
+	// SampleRatio is the fraction of new traces that are sampled, sampled parents are always followed.
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	ServiceName string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"deviced"`
 }
+
+// Health configures the checks reported on /healthz and /readyz.
+type Health struct {
+	// Timeout bounds every check, a check that doesn't complete in time fails.
+	Timeout time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" env-default:"2s"`
+	// MinFreeDiskBytes is the free space the data directory of the file storage needs for the service to be ready.
+	MinFreeDiskBytes uint64 `yaml:"min_free_disk_bytes" env:"HEALTH_MIN_FREE_DISK_BYTES" env-default:"104857600"`
+}
+
 type HttpClient struct {
 	Timeout           time.Duration `yaml:"timeout"`
 	MaxIdleConns      int           `yaml:"max_idle_conns"`
 	DisableKeepAlives bool          `yaml:"disable_keep_alives"`
+	Retry             Retry         `yaml:"retry"`
+	Breaker           Breaker       `yaml:"breaker"`
 }
 
-func LoadConfig(cfgPath string) *Config {
-	// TODO get configpath from flag
-	configPath := cfgPath
+// Retry configures retries of outgoing requests that failed transiently, see roundtripper.RetryRoundTripper.
+type Retry struct {
+	// MaxAttempts includes the first attempt, 1 disables retries.
+	MaxAttempts int           `yaml:"max_attempts" env:"HTTP_CLIENT_RETRY_MAX_ATTEMPTS" env-default:"3"`
+	BaseDelay   time.Duration `yaml:"base_delay" env-default:"100ms"`
+	MaxDelay    time.Duration `yaml:"max_delay" env-default:"5s"`
+	// BudgetRatio is the number of retries every request earns, e.g. 0.1 allows a retry per ten requests.
+	BudgetRatio float64 `yaml:"budget_ratio" env-default:"0.1"`
+	// BudgetMax is the most retries that can be saved up, it also allows the first retries of a client.
+	BudgetMax int `yaml:"budget_max" env-default:"10"`
+}
+
+// Breaker configures the circuit breakers of outgoing requests, every destination host gets its own.
+// Zero fields keep the defaults, see roundtripper.NewBreaker.
+type Breaker struct {
+	// ConsecutiveFailures trips the breaker after that many failures in a row.
+	ConsecutiveFailures uint32 `yaml:"consecutive_failures"`
+	// FailureRatio trips the breaker once this fraction of at least MinRequests requests failed
+	// within Interval, zero disables the rule.
+	FailureRatio float64 `yaml:"failure_ratio"`
+	MinRequests  uint32  `yaml:"min_requests"`
+	// Interval is how often a closed breaker clears its counts, zero never clears them.
+	Interval time.Duration `yaml:"interval"`
+	// OpenTimeout is how long an open breaker rejects requests before letting trial requests through.
+	OpenTimeout time.Duration `yaml:"open_timeout"`
+	// HalfOpenMaxRequests is how many trial requests a half-open breaker lets through.
+	HalfOpenMaxRequests uint32 `yaml:"half_open_max_requests"`
+	// FailureStatuses are response statuses counted as failures in addition to connection errors.
+	FailureStatuses []int `yaml:"failure_statuses"`
+	// IdleTimeout drops the closed breaker of a host that got no requests for that long.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
 
+// LoadConfig reads the config file, values from environment variables take precedence.
+func LoadConfig(configPath string) *Config {
 	// check if file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		log.Fatalf("config file does not exist: %s", configPath)
@@ -1,7 +1,7 @@
 package device
 
 type Device struct {
-	SerialNum string
-	Model     string
-	IP        string
+	SerialNum string `json:"serialNum" yaml:"serialNum"`
+	Model     string `json:"model" yaml:"model"`
+	IP        string `json:"ip" yaml:"ip"`
 }
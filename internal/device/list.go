@@ -0,0 +1,206 @@
+package device
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 1000
+)
+
+var (
+	ErrInvalidCursor    = errors.New("cursor is invalid")
+	ErrInvalidSortOrder = errors.New("sort order is invalid")
+	ErrInvalidLimit     = errors.New("limit should be between 1 and 1000")
+	ErrInvalidSubnet    = errors.New("ip filter should be an IP address or a CIDR")
+)
+
+// SortOrder is the field devices are listed by, "-" prefix means descending order.
+// Devices with equal field values are ordered by serial number in the same direction.
+type SortOrder string
+
+const (
+	SortBySerialNum     SortOrder = "serialNum"
+	SortBySerialNumDesc SortOrder = "-serialNum"
+	SortByModel         SortOrder = "model"
+	SortByModelDesc     SortOrder = "-model"
+)
+
+// ListQuery describes which devices to list and which page of them to return.
+type ListQuery struct {
+	// Model keeps only devices of the given model if not empty.
+	Model string
+	// Subnet keeps only devices with IP inside the network if not nil.
+	Subnet *net.IPNet
+	Sort   SortOrder
+	// Cursor is the NextCursor of the previous page, empty for the first page.
+	Cursor string
+	Limit  int
+}
+
+// Page is a single page of listed devices.
+type Page struct {
+	Devices []Device `json:"devices" yaml:"devices"`
+	// NextCursor is empty when there are no more devices.
+	NextCursor string `json:"nextCursor,omitempty" yaml:"nextCursor,omitempty"`
+}
+
+// Cursor is the decoded position after which the next page starts.
+type Cursor struct {
+	Sort      SortOrder `json:"o"`
+	Key       string    `json:"k"`
+	SerialNum string    `json:"s"`
+}
+
+// ParseSubnet parses an IP address or a CIDR into a network.
+// A single address is treated as a network of exactly that address.
+func ParseSubnet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, ErrInvalidSubnet
+		}
+		return subnet, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, ErrInvalidSubnet
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Normalize fills in defaults and checks sort order and limit.
+func (q ListQuery) Normalize() (ListQuery, error) {
+	if q.Sort == "" {
+		q.Sort = SortBySerialNum
+	}
+	switch q.Sort {
+	case SortBySerialNum, SortBySerialNumDesc, SortByModel, SortByModelDesc:
+	default:
+		return q, ErrInvalidSortOrder
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultListLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxListLimit {
+		return q, ErrInvalidLimit
+	}
+	return q, nil
+}
+
+// Matches reports whether the device passes the model and subnet filters.
+func (q ListQuery) Matches(d Device) bool {
+	if q.Model != "" && d.Model != q.Model {
+		return false
+	}
+	if q.Subnet != nil {
+		ip := net.ParseIP(d.IP)
+		if ip == nil || !q.Subnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Descending reports whether the order is descending.
+func (o SortOrder) Descending() bool {
+	return strings.HasPrefix(string(o), "-")
+}
+
+// Key returns the value of the field the device is sorted by.
+func (o SortOrder) Key(d Device) string {
+	if o.Field() == SortByModel {
+		return d.Model
+	}
+	return d.SerialNum
+}
+
+// Field returns the ascending order by the same field.
+func (o SortOrder) Field() SortOrder {
+	return SortOrder(strings.TrimPrefix(string(o), "-"))
+}
+
+// Less reports whether a goes before b in the order.
+func (o SortOrder) Less(a, b Device) bool {
+	ka, kb := o.Key(a), o.Key(b)
+	if ka == kb {
+		ka, kb = a.SerialNum, b.SerialNum
+	}
+	if o.Descending() {
+		return ka > kb
+	}
+	return ka < kb
+}
+
+// CursorAfter returns the cursor pointing right after the device.
+func (o SortOrder) CursorAfter(d Device) Cursor {
+	return Cursor{Sort: o, Key: o.Key(d), SerialNum: d.SerialNum}
+}
+
+// After reports whether the device goes after the cursor position.
+func (c Cursor) After(d Device) bool {
+	position := Device{SerialNum: c.SerialNum}
+	if c.Sort.Field() == SortByModel {
+		position.Model = c.Key
+	}
+	return c.Sort.Less(position, d)
+}
+
+// Encode returns the opaque string representation of the cursor.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses the cursor and checks that it was issued for the same sort order.
+func DecodeCursor(s string, order SortOrder) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != order {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Paginate filters, sorts and pages devices kept in memory.
+func Paginate(devices []Device, q ListQuery) (Page, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+	var cursor *Cursor
+	if q.Cursor != "" {
+		c, err := DecodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return Page{}, err
+		}
+		cursor = &c
+	}
+
+	matched := make([]Device, 0, len(devices))
+	for _, d := range devices {
+		if q.Matches(d) && (cursor == nil || cursor.After(d)) {
+			matched = append(matched, d)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return q.Sort.Less(matched[i], matched[j]) })
+
+	page := Page{Devices: matched}
+	if len(matched) > q.Limit {
+		page.Devices = matched[:q.Limit]
+		page.NextCursor = q.Sort.CursorAfter(page.Devices[q.Limit-1]).Encode()
+	}
+	return page, nil
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/app"
+	"homework/internal/device"
+
+	"github.com/sony/gobreaker"
+)
+
+// Storage checks that the storage answers a minimal list query.
+func Storage(storage app.DeviceStorage) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		_, err := storage.ListDevices(ctx, device.ListQuery{Limit: 1})
+		return err
+	})
+}
+
+// Breakers fails while any of the circuit breakers returned by breakers is open.
+func Breakers(breakers func() []*gobreaker.CircuitBreaker) Checker {
+	return CheckerFunc(func(context.Context) error {
+		for _, cb := range breakers() {
+			if cb.State() == gobreaker.StateOpen {
+				return fmt.Errorf("circuit breaker %q is open", cb.Name())
+			}
+		}
+		return nil
+	})
+}
+
+// Reloader is implemented by sources that reload their files in the background,
+// e.g. auth.HtpasswdFile.
+type Reloader interface {
+	// ReloadErr returns the error of the last reload, or nil if it succeeded.
+	ReloadErr() error
+}
+
+// Reload fails when the last reload of src failed.
+func Reload(src Reloader) Checker {
+	return CheckerFunc(func(context.Context) error {
+		return src.ReloadErr()
+	})
+}
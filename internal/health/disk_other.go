@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package health
+
+import (
+	"context"
+	"errors"
+)
+
+// DiskSpace is not supported on this platform, the check always fails.
+func DiskSpace(path string, minFree uint64) Checker {
+	return CheckerFunc(func(context.Context) error {
+		return errors.New("disk space check is not supported on this platform")
+	})
+}
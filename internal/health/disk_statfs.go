@@ -0,0 +1,23 @@
+//go:build linux || darwin
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// DiskSpace fails when the file system of path has less than minFree bytes available.
+func DiskSpace(path string, minFree uint64) Checker {
+	return CheckerFunc(func(context.Context) error {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil {
+			return fmt.Errorf("stat file system: %w", err)
+		}
+		if free := uint64(stat.Bavail) * uint64(stat.Bsize); free < minFree {
+			return fmt.Errorf("%d bytes free, want at least %d", free, minFree)
+		}
+		return nil
+	})
+}
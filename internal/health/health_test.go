@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/adapters/fakerepo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("down")
+
+func failing(context.Context) error { return errDown }
+
+func passing(context.Context) error { return nil }
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		name       string
+		register   func(r *Registry)
+		wantCode   int
+		wantStatus string
+		wantChecks []Result
+	}{
+		{
+			name:       "no checks",
+			register:   func(r *Registry) {},
+			wantCode:   http.StatusOK,
+			wantStatus: StatusOK,
+			wantChecks: []Result{},
+		},
+		{
+			name: "all pass",
+			register: func(r *Registry) {
+				r.Register("storage", CheckerFunc(passing))
+				r.Register("breakers", CheckerFunc(passing), Optional())
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: StatusOK,
+			wantChecks: []Result{
+				{Name: "storage", Status: StatusOK},
+				{Name: "breakers", Status: StatusOK, Optional: true},
+			},
+		},
+		{
+			name: "optional fails",
+			register: func(r *Registry) {
+				r.Register("storage", CheckerFunc(passing))
+				r.Register("breakers", CheckerFunc(failing), Optional())
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: StatusDegraded,
+			wantChecks: []Result{
+				{Name: "storage", Status: StatusOK},
+				{Name: "breakers", Status: StatusFail, Error: "down", Optional: true},
+			},
+		},
+		{
+			name: "required fails",
+			register: func(r *Registry) {
+				r.Register("storage", CheckerFunc(failing))
+				r.Register("breakers", CheckerFunc(failing), Optional())
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: StatusFail,
+			wantChecks: []Result{
+				{Name: "storage", Status: StatusFail, Error: "down"},
+				{Name: "breakers", Status: StatusFail, Error: "down", Optional: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(time.Second)
+			tt.register(r)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var report Report
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+			assert.Equal(t, tt.wantStatus, report.Status)
+			for i := range report.Checks {
+				assert.GreaterOrEqual(t, report.Checks[i].LatencyMs, 0.0)
+				report.Checks[i].LatencyMs = 0
+			}
+			assert.Equal(t, tt.wantChecks, report.Checks)
+		})
+	}
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	r := NewRegistry(20 * time.Millisecond)
+	r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	report := r.Run(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	assert.GreaterOrEqual(t, report.Checks[0].LatencyMs, 20.0)
+}
+
+func TestRegistry_TimeoutIgnoredByChecker(t *testing.T) {
+	r := NewRegistry(20 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	r.Register("blocking", CheckerFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	start := time.Now()
+	report := r.Run(context.Background())
+	assert.Less(t, time.Since(start), time.Second, "the probe must not wait for the checker")
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestStorage(t *testing.T) {
+	assert.NoError(t, Storage(fakerepo.NewDeviceStorage()).Check(context.Background()))
+}
+
+func TestBreakers(t *testing.T) {
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        "HTTP GET",
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures > 0 },
+	})
+	check := Breakers(func() []*gobreaker.CircuitBreaker { return []*gobreaker.CircuitBreaker{cb} })
+	assert.NoError(t, check.Check(context.Background()))
+
+	_, _ = cb.Execute(func() (any, error) { return nil, errDown })
+	assert.EqualError(t, check.Check(context.Background()), `circuit breaker "HTTP GET" is open`)
+}
+
+type reloader struct{ err error }
+
+func (r reloader) ReloadErr() error { return r.err }
+
+func TestReload(t *testing.T) {
+	assert.NoError(t, Reload(reloader{}).Check(context.Background()))
+	assert.ErrorIs(t, Reload(reloader{errDown}).Check(context.Background()), errDown)
+}
+
+func TestDiskSpace(t *testing.T) {
+	assert.NoError(t, DiskSpace(t.TempDir(), 1).Check(context.Background()))
+	assert.Error(t, DiskSpace(t.TempDir(), 1<<62).Check(context.Background()))
+	assert.Error(t, DiskSpace("/does/not/exist", 1).Check(context.Background()))
+}
@@ -0,0 +1,99 @@
+// Package logger builds the structured logger of the service and carries request-scoped loggers in contexts.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/config"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Environment defaults: local logs human-readable text, dev and prod log JSON,
+// prod drops debug messages.
+var envDefaults = map[string]config.Log{
+	"local": {Level: "debug", Format: "text"},
+	"dev":   {Level: "debug", Format: "json"},
+	"prod":  {Level: "info", Format: "json"},
+}
+
+// New returns a logger writing to w with the level and format from cfg.Log,
+// unset ones default by cfg.Env.
+func New(cfg *config.Config, w io.Writer) (*slog.Logger, error) {
+	defaults, ok := envDefaults[cfg.Env]
+	if !ok {
+		defaults = envDefaults["prod"]
+	}
+	levelName, format := cfg.Log.Level, cfg.Log.Format
+	if levelName == "" {
+		levelName = defaults.Level
+	}
+	if format == "" {
+		format = defaults.Format
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+type loggerKey struct{}
+
+// WithContext returns a copy of ctx carrying the logger.
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger stored by WithContext or slog.Default.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Attrs collects attributes added while a request is handled, e.g. its route,
+// so they end up in the request log line written once the request is done.
+type Attrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+func (a *Attrs) add(attrs ...slog.Attr) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.attrs = append(a.attrs, attrs...)
+}
+
+// List returns the collected attributes.
+func (a *Attrs) List() []slog.Attr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]slog.Attr(nil), a.attrs...)
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a copy of ctx collecting attributes added by AddAttrs into attrs.
+func WithAttrs(ctx context.Context, attrs *Attrs) context.Context {
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// AddAttrs adds attributes to the request log line, it does nothing if ctx doesn't collect them.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if a, ok := ctx.Value(attrsKey{}).(*Attrs); ok {
+		a.add(attrs...)
+	}
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"homework/internal/config"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		expected string
+	}{
+		{name: "Local logs debug text", cfg: config.Config{Env: "local"}, expected: "level=DEBUG msg=debug\nlevel=INFO msg=info\n"},
+		{name: "Prod logs info JSON", cfg: config.Config{Env: "prod"}, expected: `{"level":"INFO","msg":"info"}` + "\n"},
+		{name: "Level from config", cfg: config.Config{Env: "local", Log: config.Log{Level: "warn"}}, expected: ""},
+		{name: "Format from config", cfg: config.Config{Env: "prod", Log: config.Log{Format: "text"}}, expected: "level=INFO msg=info\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := New(&tt.cfg, &buf)
+			assert.NoError(t, err)
+
+			// drop the time to compare the output
+			l = slog.New(withoutTime{l.Handler()})
+			l.Debug("debug")
+			l.Info("info")
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+type withoutTime struct{ slog.Handler }
+
+func (h withoutTime) Handle(ctx context.Context, r slog.Record) error {
+	r.Time = time.Time{}
+	return h.Handler.Handle(ctx, r)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(&config.Config{Log: config.Log{Level: "verbose"}}, &bytes.Buffer{})
+	assert.Error(t, err)
+	_, err = New(&config.Config{Log: config.Log{Format: "xml"}}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestAddAttrs(t *testing.T) {
+	AddAttrs(context.Background(), slog.String("ignored", "without collector"))
+
+	attrs := new(Attrs)
+	ctx := WithAttrs(context.Background(), attrs)
+	AddAttrs(ctx, slog.String("route", "/v1/devices"))
+	assert.Equal(t, []slog.Attr{slog.String("route", "/v1/devices")}, attrs.List())
+}
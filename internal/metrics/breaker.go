@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+)
+
+// BreakerCreated reports the initial closed state of the breaker.
+func BreakerCreated(name string) {
+	breakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+}
+
+// BreakerRemoved drops the series of a breaker that is no longer used.
+func BreakerRemoved(name string) {
+	breakerState.DeleteLabelValues(name)
+	breakerTransitions.DeletePartialMatch(prometheus.Labels{"name": name})
+}
+
+// BreakerStateChanged matches gobreaker.Settings.OnStateChange.
+func BreakerStateChanged(name string, from, to gobreaker.State) {
+	breakerState.WithLabelValues(name).Set(float64(to))
+	breakerTransitions.WithLabelValues(name, from.String(), to.String()).Inc()
+}
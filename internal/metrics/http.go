@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"homework/internal/reqinfo"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests no handler set a route for, so arbitrary paths
+// don't create new time series.
+const unmatchedRoute = "unmatched"
+
+// Middleware counts requests and observes their latency by method, status and the route
+// set with reqinfo.SetRoute.
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		w, r, info := reqinfo.Record(w, r)
+
+		h.ServeHTTP(w, r)
+
+		route := info.Route()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(info.Status())
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"homework/internal/app"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var devicesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "devices"),
+	"Devices in the inventory by model.",
+	[]string{"model"}, nil,
+)
+
+// InventoryCollector asks the storage for the number of devices per model on every scrape.
+type InventoryCollector struct {
+	storage app.DeviceStorage
+}
+
+func NewInventoryCollector(storage app.DeviceStorage) *InventoryCollector {
+	return &InventoryCollector{storage: storage}
+}
+
+func (c *InventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- devicesDesc
+}
+
+func (c *InventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.storage.CountDevicesByModel(context.Background())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(devicesDesc, err)
+		return
+	}
+	for model, count := range counts {
+		ch <- prometheus.MustNewConstMetric(devicesDesc, prometheus.GaugeValue, float64(count), model)
+	}
+}
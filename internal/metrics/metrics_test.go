@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/adapters/fakerepo"
+	"homework/internal/app"
+	"homework/internal/device"
+	"homework/internal/reqinfo"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/devices/1234" {
+			reqinfo.SetRoute(r.Context(), "/v1/devices/{id}")
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("/v1/devices/{id}", "GET", "404"))
+	beforeUnmatched := testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, "GET", "200"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/devices/1234", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/wp-login.php", nil))
+
+	assert.Equal(t, before+1, testutil.ToFloat64(httpRequests.WithLabelValues("/v1/devices/{id}", "GET", "404")))
+	assert.Equal(t, beforeUnmatched+1, testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, "GET", "200")))
+}
+
+type failingStorage struct {
+	app.DeviceStorage
+}
+
+func (failingStorage) CountDevicesByModel(context.Context) (map[string]int, error) {
+	return nil, app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to count devices", errors.New("disk I/O error"))
+}
+
+func TestStorage(t *testing.T) {
+	storage := NewStorage(fakerepo.NewDeviceStorage())
+	before := testutil.ToFloat64(storageErrors.WithLabelValues("get", "not_found"))
+
+	require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: "1234", Model: "hp", IP: "1.1.1.1"}))
+	_, err := storage.GetDeviceBySerialNum(context.Background(), "1234")
+	require.NoError(t, err)
+	_, err = storage.GetDeviceBySerialNum(context.Background(), "5678")
+	require.Error(t, err)
+
+	assert.Equal(t, before+1, testutil.ToFloat64(storageErrors.WithLabelValues("get", "not_found")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(storageErrors.WithLabelValues("create", "conflict")))
+	assert.Equal(t, 2, testutil.CollectAndCount(storageDuration), "get and create are observed")
+}
+
+func TestInventoryCollector(t *testing.T) {
+	storage := fakerepo.NewDeviceStorage()
+	for i, model := range []string{"hp", "hp", "dell"} {
+		require.NoError(t, storage.CreateDevice(context.Background(), device.Device{SerialNum: string(rune('a'+i)) + "123", Model: model, IP: "1.1.1.1"}))
+	}
+
+	expected := `
+# HELP deviced_devices Devices in the inventory by model.
+# TYPE deviced_devices gauge
+deviced_devices{model="dell"} 1
+deviced_devices{model="hp"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewInventoryCollector(storage), strings.NewReader(expected)))
+
+	assert.Error(t, testutil.CollectAndCompare(NewInventoryCollector(failingStorage{storage}), strings.NewReader("")))
+}
+
+func TestBreakerStateChanged(t *testing.T) {
+	BreakerCreated("test")
+	assert.Equal(t, float64(0), testutil.ToFloat64(breakerState.WithLabelValues("test")))
+
+	BreakerStateChanged("test", gobreaker.StateClosed, gobreaker.StateOpen)
+	assert.Equal(t, float64(2), testutil.ToFloat64(breakerState.WithLabelValues("test")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(breakerTransitions.WithLabelValues("test", "closed", "open")))
+
+	BreakerRemoved("test")
+	assert.False(t, breakerState.DeleteLabelValues("test"), "state series must be dropped")
+	assert.Zero(t, breakerTransitions.DeletePartialMatch(prometheus.Labels{"name": "test"}), "transition series must be dropped")
+}
+
+func TestHandler(t *testing.T) {
+	Middleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "go_goroutines")
+	assert.Contains(t, rr.Body.String(), "# TYPE deviced_http_requests_total counter")
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"homework/internal/app"
+	"homework/internal/device"
+	"io"
+	"time"
+)
+
+// Storage observes the latency and errors of every operation of the wrapped storage.
+type Storage struct {
+	next app.DeviceStorage
+}
+
+func NewStorage(next app.DeviceStorage) *Storage {
+	return &Storage{next: next}
+}
+
+func (s *Storage) GetDeviceBySerialNum(ctx context.Context, serialNum string) (device.Device, error) {
+	defer observe("get", time.Now())
+	d, err := s.next.GetDeviceBySerialNum(ctx, serialNum)
+	countError("get", err)
+	return d, err
+}
+
+func (s *Storage) CreateDevice(ctx context.Context, d device.Device) error {
+	defer observe("create", time.Now())
+	err := s.next.CreateDevice(ctx, d)
+	countError("create", err)
+	return err
+}
+
+func (s *Storage) DeleteDeviceBySerialNum(ctx context.Context, serialNum string) error {
+	defer observe("delete", time.Now())
+	err := s.next.DeleteDeviceBySerialNum(ctx, serialNum)
+	countError("delete", err)
+	return err
+}
+
+func (s *Storage) UpdateDevice(ctx context.Context, d device.Device) error {
+	defer observe("update", time.Now())
+	err := s.next.UpdateDevice(ctx, d)
+	countError("update", err)
+	return err
+}
+
+func (s *Storage) ListDevices(ctx context.Context, query device.ListQuery) (device.Page, error) {
+	defer observe("list", time.Now())
+	page, err := s.next.ListDevices(ctx, query)
+	countError("list", err)
+	return page, err
+}
+
+func (s *Storage) CountDevicesByModel(ctx context.Context) (map[string]int, error) {
+	defer observe("count", time.Now())
+	counts, err := s.next.CountDevicesByModel(ctx)
+	countError("count", err)
+	return counts, err
+}
+
+// Close closes the wrapped storage if it holds resources.
+func (s *Storage) Close() error {
+	if closer, ok := s.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func observe(operation string, start time.Time) {
+	storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func countError(operation string, err error) {
+	if err != nil {
+		storageErrors.WithLabelValues(operation, errorKind(err)).Inc()
+	}
+}
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, app.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, app.ErrConflict):
+		return "conflict"
+	case errors.Is(err, app.ErrValidation):
+		return "validation"
+	case errors.Is(err, app.ErrUnavailable):
+		return "unavailable"
+	default:
+		return "other"
+	}
+}
@@ -1,55 +1,241 @@
 package middleware
 
 import (
-	"log"
+	"homework/internal/app"
+	"homework/internal/auth"
+	"homework/internal/logger"
+	"homework/internal/problem"
+	"homework/internal/redact"
+	"homework/internal/reqinfo"
+	"homework/internal/requestid"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type responseWriter struct {
 	http.ResponseWriter
-	status int
-	body   string
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.status = code
-	rw.ResponseWriter.WriteHeader(code)
+	info *reqinfo.Info
+	body string
 }
 
+// Write keeps bodies of error responses to log them.
 func (rw *responseWriter) Write(p []byte) (int, error) {
-	rw.body += string(p)
+	if rw.info.Status() >= http.StatusBadRequest {
+		rw.body += string(p)
+	}
 	return rw.ResponseWriter.Write(p)
 }
 
+// LoggingMiddleware logs requests with slog.Default and values redacted by redact.DefaultPolicy.
 func LoggingMiddleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Incoming Request: method %s, endpoint: %s", r.Method, r.URL)
-		log.Printf("Headers: %+v, Query Parameters: %+v", r.Header, r.URL.Query())
+	return NewLoggingMiddleware(slog.Default(), redact.DefaultPolicy())(h)
+}
+
+// NewLoggingMiddleware logs a line per request with its method, path, status and latency,
+// plus the route set with reqinfo.SetRoute and attributes added by handlers with logger.AddAttrs,
+// e.g. serialNum.
+// The request ID and trace ID are logged when the request has them.
+// Handlers get a logger with the request fields from logger.FromContext.
+// Headers, query parameters and fields of JSON error bodies are redacted by the policy.
+func NewLoggingMiddleware(l *slog.Logger, policy *redact.Policy) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLogger := l.With(slog.String("method", r.Method), slog.String("path", policy.URL(r.URL)))
+			if id, ok := requestid.FromContext(r.Context()); ok {
+				reqLogger = reqLogger.With(slog.String("request_id", id))
+			}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				reqLogger = reqLogger.With(slog.String("trace_id", sc.TraceID().String()))
+			}
+			reqLogger.Debug("request received",
+				slog.Any("headers", policy.Header(r.Header)),
+				slog.Any("query", policy.Query(r.URL.Query())))
 
-		rw := &responseWriter{w, http.StatusOK, ""}
+			attrs := new(logger.Attrs)
+			ctx := logger.WithAttrs(logger.WithContext(r.Context(), reqLogger), attrs)
+			w, r, info := reqinfo.Record(w, r.WithContext(ctx))
+			rw := &responseWriter{ResponseWriter: w, info: info}
 
-		h.ServeHTTP(rw, r)
+			h.ServeHTTP(rw, r)
 
-		log.Printf("Outgoing Response Headers: %+v", w.Header())
-		log.Printf("status: %v", rw.status)
-		if rw.status != http.StatusOK {
-			log.Printf("error: %s", rw.body)
+			var fields []slog.Attr
+			if route := info.Route(); route != "" {
+				fields = append(fields, slog.String("route", route))
+			}
+			fields = append(fields, attrs.List()...)
+			fields = append(fields,
+				slog.Int("status", info.Status()),
+				slog.Duration("latency", time.Since(start)),
+			)
+			level := slog.LevelInfo
+			switch {
+			case info.Status() >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case info.Status() >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			if rw.body != "" {
+				fields = append(fields, slog.String("error", string(policy.JSON([]byte(rw.body)))))
+			}
+			reqLogger.LogAttrs(ctx, level, "request completed", fields...)
+			reqLogger.Debug("response headers", slog.Any("headers", policy.Header(w.Header())))
+		})
+	}
+}
+
+// RequestIDMiddleware stores the X-Request-ID of the request in the context and echoes it
+// in the response. Requests without a valid ID get a new one.
+func RequestIDMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
 		}
+		w.Header().Set(requestid.Header, id)
+		h.ServeHTTP(w, r.WithContext(requestid.WithContext(r.Context(), id)))
 	})
 }
 
-func BasicAuthMiddleware(h http.Handler) http.Handler {
+// BasicAuthMiddleware lets through requests with credentials accepted by the authenticator
+// and stores the authenticated principal in the request context.
+// Requests already authenticated by an outer middleware are let through as is.
+func BasicAuthMiddleware(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.PrincipalFromContext(r.Context()); ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			username, pass, ok := r.BasicAuth()
+			if !ok {
+				unauthorized(w)
+				return
+			}
+			principal, err := authenticator.Authenticate(username, pass)
+			if err != nil {
+				unauthorized(w)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="deviced", charset="UTF-8"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// ClientCertMiddleware authenticates requests made over TLS connections with a verified client
+// certificate. Requests already authenticated by an outer middleware, e.g. with a JWT, and requests
+// without a certificate are passed on untouched. Certificates named in admins are granted every scope.
+func ClientCertMiddleware(admins auth.Admins) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.PrincipalFromContext(r.Context()); ok || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			principal, ok := auth.ClientCertPrincipal(r.TLS.VerifiedChains[0][0], admins)
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// APIKeyMiddleware authenticates requests carrying an API key in the X-API-Key header
+// or as an "Authorization: Bearer" token. Requests without a key are passed on untouched,
+// so other authentication middlewares can handle them.
+func APIKeyMiddleware(keys auth.KeyAuthenticator) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := apiKey(r)
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			principal, err := keys.AuthenticateKey(key)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="deviced", error="invalid_token"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func apiKey(r *http.Request) (string, bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, true
+	}
+	if token, ok := bearerToken(r); ok && !auth.IsJWT(token) {
+		return token, true
+	}
+	return "", false
+}
+
+// JWTMiddleware authenticates requests carrying a JWT as an "Authorization: Bearer" token
+// and stores the principal with the token claims in the request context.
+// Requests without a JWT are passed on untouched.
+func JWTMiddleware(verifier auth.TokenVerifier) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok || !auth.IsJWT(token) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			principal, err := verifier.VerifyToken(token)
+			if err != nil {
+				logger.FromContext(r.Context()).Warn("rejected JWT", slog.Any("error", err))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="deviced", error="invalid_token"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") && token != "" {
+		return token, true
+	}
+	return "", false
+}
+
+// ScopeMiddleware rejects requests whose principal lacks the scope of the operation:
+// admin for /v1/admin/, devices:read for GET and HEAD, devices:write for other methods.
+// Rejections are problem responses, like the ones of the handlers.
+func ScopeMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		username, pass, ok := r.BasicAuth()
-		if !ok || !checkCredentials(username, pass) {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		scope := requiredScope(r)
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok || !principal.HasScope(scope) {
+			problem.WriteError(w, r, app.NewError(app.ErrForbidden, "insufficient_scope", "the "+string(scope)+" scope is required"))
 			return
 		}
 		h.ServeHTTP(w, r)
 	})
 }
 
-// just mock, here could be checking in storage
-func checkCredentials(username, password string) bool {
-	return username == "user" && password == "password"
+func requiredScope(r *http.Request) auth.Scope {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/admin/"):
+		return auth.ScopeAdmin
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return auth.ScopeDevicesRead
+	default:
+		return auth.ScopeDevicesWrite
+	}
 }
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"homework/internal/auth"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const apiKeysPath = "/v1/admin/api-keys"
+
+var ErrInvalidAPIKeyBody = errors.New("request body is not a valid API key JSON")
+
+// APIKeyStore issues and revokes API keys.
+type APIKeyStore interface {
+	Create(label string, scopes []auth.Scope, expiresAt time.Time) (auth.APIKey, string, error)
+	List() []auth.APIKey
+	Revoke(id string) error
+}
+
+// WithAPIKeys mounts the admin endpoint managing API keys.
+func WithAPIKeys(keys APIKeyStore) Option {
+	return func(h *Handler) {
+		h.apiKeys = keys
+	}
+}
+
+type apiKeyRequest struct {
+	Label  string       `json:"label"`
+	Scopes []auth.Scope `json:"scopes"`
+	// ExpiresAt of nil means the key never expires.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// createdAPIKey is the only response that contains the key itself.
+type createdAPIKey struct {
+	auth.APIKey
+	Key string `json:"key"`
+}
+
+type apiKeyList struct {
+	APIKeys []auth.APIKey `json:"apiKeys"`
+}
+
+// handleAPIKeys serves the API key collection: GET lists keys, POST issues one.
+func (h *Handler) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, r, http.StatusOK, apiKeyList{APIKeys: h.apiKeys.List()})
+	case http.MethodPost:
+		var req apiKeyRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrInvalidAPIKeyBody)
+			return
+		}
+		var expiresAt time.Time
+		if req.ExpiresAt != nil {
+			expiresAt = *req.ExpiresAt
+		}
+		key, secret, err := h.apiKeys.Create(req.Label, req.Scopes, expiresAt)
+		if err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		w.Header().Set("Location", apiKeysPath+"/"+key.ID)
+		writeJSON(w, r, http.StatusCreated, createdAPIKey{APIKey: key, Key: secret})
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleAPIKey revokes the key addressed by /v1/admin/api-keys/{id}.
+func (h *Handler) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, apiKeysPath+"/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, r, http.StatusNotFound, ErrResourceNotFound)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, r, http.MethodDelete)
+		return
+	}
+	if err := h.apiKeys.Revoke(id); err != nil {
+		writeError(w, r, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/auth"
+	"homework/internal/ports/handler/mocks"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler_APIKeys(t *testing.T) {
+	keys, err := auth.NewAPIKeys(filepath.Join(t.TempDir(), "api_keys.json"))
+	require.NoError(t, err)
+	handler := NewHandler(mocks.NewService(t), WithAPIKeys(keys)).InitRoutes()
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, strings.NewReader(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("POST", "/v1/admin/api-keys", `{"label":"ci","scopes":["devices:read"],"expiresAt":"2099-01-01T00:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created createdAPIKey
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.Equal(t, "ci", created.Label)
+	assert.Equal(t, []auth.Scope{auth.ScopeDevicesRead}, created.Scopes)
+	assert.Equal(t, "/v1/admin/api-keys/"+created.ID, rr.Header().Get("Location"))
+	_, err = keys.AuthenticateKey(created.Key)
+	assert.NoError(t, err)
+
+	rr = serve("GET", "/v1/admin/api-keys", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), created.ID)
+	assert.NotContains(t, rr.Body.String(), created.Key)
+
+	rr = serve("POST", "/v1/admin/api-keys", `{"label":"ci","scopes":["root"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, "invalid_scope", problem.Code)
+
+	rr = serve("POST", "/v1/admin/api-keys", `{"label":`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrInvalidAPIKeyBody.Error())
+
+	rr = serve("DELETE", "/v1/admin/api-keys/"+created.ID, "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = serve("DELETE", "/v1/admin/api-keys/"+created.ID, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestHandler_APIKeysDisabled(t *testing.T) {
+	handler := NewHandler(mocks.NewService(t)).InitRoutes()
+
+	req, err := http.NewRequest("GET", "/v1/admin/api-keys", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -3,120 +3,156 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"homework/internal/app"
 	"homework/internal/device"
-	"homework/internal/ports/handler/validate"
-	"log"
+	"homework/internal/logger"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 var (
 	ErrInvalidMethod = errors.New("invalid http method")
 )
 
-type MyError struct {
-	Message string `json:"message"`
-}
-
-func (e *MyError) Error() string {
-	return e.Message
-}
-
-func writeError(w http.ResponseWriter, statusCode int, err error) {
-	myErr := &MyError{Message: err.Error()}
-	jsonErr, err := json.Marshal(myErr)
+func writeJSON(w http.ResponseWriter, r *http.Request, statusCode int, v any) {
+	data, err := json.Marshal(v)
 	if err != nil {
-		http.Error(w, "Failed to marshal error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	_, err = w.Write(jsonErr)
-	if err != nil {
-		log.Printf("Failed to write error, %d", http.StatusInternalServerError)
-		return
+	if _, err := w.Write(data); err != nil {
+		logger.FromContext(r.Context()).Error("failed to write response", slog.Any("error", err))
 	}
-
 }
 
 func (h *Handler) handleGetDevice(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
-		writeError(w, http.StatusBadRequest, ErrInvalidMethod)
+		writeError(w, r, http.StatusBadRequest, ErrInvalidMethod)
 		return
 	}
 	serialNum := r.Header.Get("serialNum")
-	if err := validate.IsValidSerialNum(serialNum); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+	logSerialNum(r, serialNum)
+	if err := h.validator.IsValidSerialNum(serialNum); err != nil {
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
-	d, err := h.service.GetDevice(serialNum)
+	d, err := h.service.GetDevice(r.Context(), serialNum)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
 
-	fmt.Printf("Device with SerialNum %s, Model %s, IP %s\n", d.SerialNum, d.Model, d.IP)
-	w.WriteHeader(http.StatusOK)
+	writeNegotiated(w, r, http.StatusOK, d)
 }
 
 func (h *Handler) handleCreateDevice(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		writeError(w, http.StatusBadRequest, ErrInvalidMethod)
+		writeError(w, r, http.StatusBadRequest, ErrInvalidMethod)
 		return
 	}
 	serialNum := r.Header.Get("serialNum")
 	Model := r.Header.Get("Model")
 	IP := r.Header.Get("IP")
 	device := device.Device{SerialNum: serialNum, Model: Model, IP: IP}
-	if err := validate.ValidateDevice(device); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+	logSerialNum(r, serialNum)
+	if err := h.validator.ValidateDevice(device); err != nil {
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
-	err := h.service.CreateDevice(device)
+	err := h.service.CreateDevice(r.Context(), device)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
-	fmt.Println("Device succsesfully created")
+	logger.FromContext(r.Context()).Info("device created", slog.String("serialNum", device.SerialNum))
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) handleDeleteDevice(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
-		writeError(w, http.StatusBadRequest, ErrInvalidMethod)
+		writeError(w, r, http.StatusBadRequest, ErrInvalidMethod)
 		return
 	}
 	serialNum := r.Header.Get("serialNum")
-	if err := validate.IsValidSerialNum(serialNum); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+	logSerialNum(r, serialNum)
+	if err := h.validator.IsValidSerialNum(serialNum); err != nil {
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
-	err := h.service.DeleteDevice(serialNum)
+	err := h.service.DeleteDevice(r.Context(), serialNum)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
-	fmt.Println("Device succsesfully deleted")
+	logger.FromContext(r.Context()).Info("device deleted", slog.String("serialNum", serialNum))
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) handleUpdateDevice(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "PUT" {
-		writeError(w, http.StatusBadRequest, ErrInvalidMethod)
+		writeError(w, r, http.StatusBadRequest, ErrInvalidMethod)
 		return
 	}
 	serialNum := r.Header.Get("serialNum")
 	Model := r.Header.Get("Model")
 	IP := r.Header.Get("IP")
 	device := device.Device{SerialNum: serialNum, Model: Model, IP: IP}
-	if err := validate.ValidateDevice(device); err != nil {
-		writeError(w, http.StatusBadRequest, err)
+	logSerialNum(r, serialNum)
+	if err := h.validator.ValidateDevice(device); err != nil {
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
-	err := h.service.UpdateDevice(device)
+	err := h.service.UpdateDevice(r.Context(), device)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, r, errorStatus(err), err)
 		return
 	}
-	fmt.Println("Device successfully updated")
+	logger.FromContext(r.Context()).Info("device updated", slog.String("serialNum", device.SerialNum))
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *Handler) handleListDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, r, http.StatusBadRequest, ErrInvalidMethod)
+		return
+	}
+	query, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, r, errorStatus(err), err)
+		return
+	}
+	page, err := h.service.ListDevices(r.Context(), query)
+	if err != nil {
+		writeError(w, r, errorStatus(err), err)
+		return
+	}
+	writeNegotiated(w, r, http.StatusOK, page)
+}
+
+// parseListQuery only parses the query parameters, DeviceService.ListDevices validates the query.
+func parseListQuery(values url.Values) (device.ListQuery, error) {
+	query := device.ListQuery{
+		Model:  values.Get("model"),
+		Sort:   device.SortOrder(values.Get("sort")),
+		Cursor: values.Get("cursor"),
+	}
+	if ip := values.Get("ip"); ip != "" {
+		subnet, err := device.ParseSubnet(ip)
+		if err != nil {
+			return query, app.InvalidListQuery(err)
+		}
+		query.Subnet = subnet
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, app.InvalidListQuery(device.ErrInvalidLimit)
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
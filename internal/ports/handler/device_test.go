@@ -3,9 +3,12 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/bxcodec/faker"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 	"homework/internal/adapters/fakerepo"
 	"homework/internal/app"
 	"homework/internal/device"
@@ -36,7 +39,7 @@ func TestHandler_handleGetDevice(t *testing.T) {
 			name:          "No Such Device",
 			method:        "GET",
 			serialNum:     "1234",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusNotFound,
 			expectedError: fakerepo.ErrNoSuchDevice,
 			respErr:       fakerepo.ErrNoSuchDevice,
 		},
@@ -51,14 +54,14 @@ func TestHandler_handleGetDevice(t *testing.T) {
 			name:          "Invalid SerialNum",
 			method:        "GET",
 			serialNum:     "invalid@serial",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusUnprocessableEntity,
 			expectedError: validate.ErrSerialNumChar,
 		},
 		{
 			name:          "Invalid SerialNum",
 			method:        "GET",
 			serialNum:     "i",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusUnprocessableEntity,
 			expectedError: validate.ErrSerialNumLength,
 		},
 	}
@@ -66,14 +69,15 @@ func TestHandler_handleGetDevice(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			serviceMock := mocks.NewService(t)
 			h := &Handler{
-				service: serviceMock,
+				service:      serviceMock,
+				legacyRoutes: true,
 			}
 			handler := h.InitRoutes()
 			if tt.respErr == nil {
-				serviceMock.On("GetDevice", tt.serialNum).
+				serviceMock.On("GetDevice", mock.Anything, tt.serialNum).
 					Return(device.Device{SerialNum: tt.serialNum, Model: "HP", IP: "111.111.111.111"}, nil).Maybe()
 			} else {
-				serviceMock.On("GetDevice", tt.serialNum).
+				serviceMock.On("GetDevice", mock.Anything, tt.serialNum).
 					Return(device.Device{}, tt.respErr).Maybe()
 			}
 			req, err := http.NewRequest(tt.method, "/getDevice", bytes.NewReader([]byte{}))
@@ -85,16 +89,16 @@ func TestHandler_handleGetDevice(t *testing.T) {
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(t, tt.expectedCode, rr.Code)
-			if tt.expectedCode == http.StatusBadRequest {
-				expectedError := MyError{Message: tt.expectedError.Error()}
-				actualError := MyError{}
+			if tt.expectedError != nil {
+				actualError := Problem{}
 
 				err := json.Unmarshal(rr.Body.Bytes(), &actualError)
 				if err != nil {
 					assert.Fail(t, "error of unmarshalling error")
 				}
 
-				assert.Equal(t, expectedError, actualError)
+				assert.Equal(t, tt.expectedCode, actualError.Status)
+				assert.Equal(t, tt.expectedError.Error(), actualError.Detail)
 			}
 		})
 	}
@@ -119,7 +123,7 @@ func TestHandler_handleDeleteDevice(t *testing.T) {
 			name:          "No Such Device",
 			method:        "DELETE",
 			serialNum:     "1234",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusNotFound,
 			expectedError: fakerepo.ErrNoSuchDevice,
 			respErr:       fakerepo.ErrNoSuchDevice,
 		},
@@ -134,14 +138,14 @@ func TestHandler_handleDeleteDevice(t *testing.T) {
 			name:          "Invalid SerialNum: char",
 			method:        "DELETE",
 			serialNum:     "invalid@serial",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusUnprocessableEntity,
 			expectedError: validate.ErrSerialNumChar,
 		},
 		{
 			name:          "Invalid SerialNum: len",
 			method:        "DELETE",
 			serialNum:     "i",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusUnprocessableEntity,
 			expectedError: validate.ErrSerialNumLength,
 		},
 	}
@@ -149,14 +153,15 @@ func TestHandler_handleDeleteDevice(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			serviceMock := mocks.NewService(t)
 			h := &Handler{
-				service: serviceMock,
+				service:      serviceMock,
+				legacyRoutes: true,
 			}
 			handler := h.InitRoutes()
 			if tt.respErr == nil {
-				serviceMock.On("DeleteDevice", tt.serialNum).
+				serviceMock.On("DeleteDevice", mock.Anything, tt.serialNum).
 					Return(nil).Maybe()
 			} else {
-				serviceMock.On("DeleteDevice", tt.serialNum).
+				serviceMock.On("DeleteDevice", mock.Anything, tt.serialNum).
 					Return(tt.respErr).Maybe()
 			}
 			req, err := http.NewRequest(tt.method, "/deleteDevice", bytes.NewReader([]byte{}))
@@ -168,16 +173,16 @@ func TestHandler_handleDeleteDevice(t *testing.T) {
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(t, tt.expectedCode, rr.Code)
-			if tt.expectedCode == http.StatusBadRequest {
-				expectedError := MyError{Message: tt.expectedError.Error()}
-				actualError := MyError{}
+			if tt.expectedError != nil {
+				actualError := Problem{}
 
 				err := json.Unmarshal(rr.Body.Bytes(), &actualError)
 				if err != nil {
 					assert.Fail(t, "error of unmarshalling error")
 				}
 
-				assert.Equal(t, expectedError, actualError)
+				assert.Equal(t, tt.expectedCode, actualError.Status)
+				assert.Equal(t, tt.expectedError.Error(), actualError.Detail)
 			}
 		})
 	}
@@ -209,7 +214,7 @@ func TestHandler_handleCreateDevice(t *testing.T) {
 			serialNum:     "1234",
 			model:         "hp",
 			ip:            "121.121.212.121",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusNotFound,
 			expectedError: fakerepo.ErrNoSuchDevice,
 			respErr:       fakerepo.ErrNoSuchDevice,
 		},
@@ -228,8 +233,8 @@ func TestHandler_handleCreateDevice(t *testing.T) {
 			serialNum:     "",
 			model:         "hp",
 			ip:            "121.121.212.121",
-			expectedCode:  http.StatusBadRequest,
-			expectedError: validate.ErrDeviceEmptyField,
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: validate.ValidateDevice(device.Device{Model: "hp", IP: "121.121.212.121"}),
 		},
 		{
 			name:          "Invalid device: IP",
@@ -237,22 +242,23 @@ func TestHandler_handleCreateDevice(t *testing.T) {
 			serialNum:     "3213",
 			model:         "hp",
 			ip:            "121.121ad.212.121.1311",
-			expectedCode:  http.StatusBadRequest,
-			expectedError: validate.ErrDeviceInvalidIP,
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: validate.ValidateDevice(device.Device{SerialNum: "3213", Model: "hp", IP: "121.121ad.212.121.1311"}),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			serviceMock := mocks.NewService(t)
 			h := &Handler{
-				service: serviceMock,
+				service:      serviceMock,
+				legacyRoutes: true,
 			}
 			handler := h.InitRoutes()
 			if tt.respErr == nil {
-				serviceMock.On("CreateDevice", device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
+				serviceMock.On("CreateDevice", mock.Anything, device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
 					Return(nil).Maybe()
 			} else {
-				serviceMock.On("CreateDevice", device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
+				serviceMock.On("CreateDevice", mock.Anything, device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
 					Return(tt.respErr).Maybe()
 			}
 			req, err := http.NewRequest(tt.method, "/createDevice", bytes.NewReader([]byte{}))
@@ -265,16 +271,16 @@ func TestHandler_handleCreateDevice(t *testing.T) {
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(t, tt.expectedCode, rr.Code)
-			if tt.expectedCode == http.StatusBadRequest {
-				expectedError := MyError{Message: tt.expectedError.Error()}
-				actualError := MyError{}
+			if tt.expectedError != nil {
+				actualError := Problem{}
 
 				err := json.Unmarshal(rr.Body.Bytes(), &actualError)
 				if err != nil {
 					assert.Fail(t, "error of unmarshalling error")
 				}
 
-				assert.Equal(t, expectedError, actualError)
+				assert.Equal(t, tt.expectedCode, actualError.Status)
+				assert.Equal(t, tt.expectedError.Error(), actualError.Detail)
 			}
 		})
 	}
@@ -306,7 +312,7 @@ func TestHandler_handleUpdateDevice(t *testing.T) {
 			serialNum:     "1234",
 			model:         "hp",
 			ip:            "121.121.212.121",
-			expectedCode:  http.StatusBadRequest,
+			expectedCode:  http.StatusNotFound,
 			respErr:       fakerepo.ErrNoSuchDevice,
 			expectedError: fakerepo.ErrNoSuchDevice,
 		},
@@ -324,14 +330,15 @@ func TestHandler_handleUpdateDevice(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			serviceMock := mocks.NewService(t)
 			h := &Handler{
-				service: serviceMock,
+				service:      serviceMock,
+				legacyRoutes: true,
 			}
 			handler := h.InitRoutes()
 			if tt.respErr == nil {
-				serviceMock.On("UpdateDevice", device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
+				serviceMock.On("UpdateDevice", mock.Anything, device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
 					Return(nil).Maybe()
 			} else {
-				serviceMock.On("UpdateDevice", device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
+				serviceMock.On("UpdateDevice", mock.Anything, device.Device{SerialNum: tt.serialNum, Model: tt.model, IP: tt.ip}).
 					Return(tt.respErr).Maybe()
 			}
 			req, err := http.NewRequest(tt.method, "/updateDevice", bytes.NewReader([]byte{}))
@@ -344,17 +351,163 @@ func TestHandler_handleUpdateDevice(t *testing.T) {
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(t, tt.expectedCode, rr.Code)
-			if tt.expectedCode == http.StatusBadRequest {
-				expectedError := MyError{Message: tt.expectedError.Error()}
-				actualError := MyError{}
+			if tt.expectedError != nil {
+				actualError := Problem{}
 
 				err := json.Unmarshal(rr.Body.Bytes(), &actualError)
 				if err != nil {
 					assert.Fail(t, "error of unmarshalling error")
 				}
 
-				assert.Equal(t, expectedError, actualError)
+				assert.Equal(t, tt.expectedCode, actualError.Status)
+				assert.Equal(t, tt.expectedError.Error(), actualError.Detail)
+			}
+		})
+	}
+}
+
+func TestHandler_handleListDevices(t *testing.T) {
+	subnet, err := device.ParseSubnet("10.0.0.0/24")
+	require.NoError(t, err)
+	cursor := device.SortByModelDesc.CursorAfter(device.Device{SerialNum: "1234", Model: "hp"}).Encode()
+	storageErr := app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to list devices", errors.New("disk I/O error"))
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		accept        string
+		query         device.ListQuery
+		expectedCode  int
+		expectedError error
+		respErr       error
+	}{
+		{
+			name:         "Success with defaults",
+			method:       "GET",
+			target:       "/listDevices",
+			query:        device.ListQuery{},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Success with filters",
+			method:       "GET",
+			target:       "/listDevices?model=hp&ip=10.0.0.0/24&sort=-model&cursor=" + cursor + "&limit=10",
+			query:        device.ListQuery{Model: "hp", Subnet: subnet, Sort: device.SortByModelDesc, Cursor: cursor, Limit: 10},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Success in YAML",
+			method:       "GET",
+			target:       "/listDevices",
+			accept:       "application/yaml",
+			query:        device.ListQuery{},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:          "Not acceptable",
+			method:        "GET",
+			target:        "/listDevices",
+			accept:        "image/png",
+			query:         device.ListQuery{},
+			expectedCode:  http.StatusNotAcceptable,
+			expectedError: ErrNotAcceptable,
+		},
+		{
+			name:          "respErr",
+			method:        "GET",
+			target:        "/listDevices",
+			query:         device.ListQuery{},
+			expectedCode:  http.StatusServiceUnavailable,
+			expectedError: errors.New(storageErr.Message),
+			respErr:       storageErr,
+		},
+		{
+			name:          "Invalid cursor",
+			method:        "GET",
+			target:        "/listDevices?cursor=abc",
+			query:         device.ListQuery{Cursor: "abc"},
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: device.ErrInvalidCursor,
+			respErr:       app.InvalidListQuery(device.ErrInvalidCursor),
+		},
+		{
+			name:          "Invalid http Method",
+			method:        "POST",
+			target:        "/listDevices",
+			expectedCode:  http.StatusBadRequest,
+			expectedError: ErrInvalidMethod,
+		},
+		{
+			name:          "Invalid ip filter",
+			method:        "GET",
+			target:        "/listDevices?ip=10.0.0.0/99",
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: device.ErrInvalidSubnet,
+		},
+		{
+			name:          "Invalid sort",
+			method:        "GET",
+			target:        "/listDevices?sort=ip",
+			query:         device.ListQuery{Sort: "ip"},
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: device.ErrInvalidSortOrder,
+			respErr:       app.InvalidListQuery(device.ErrInvalidSortOrder),
+		},
+		{
+			name:          "Invalid limit",
+			method:        "GET",
+			target:        "/listDevices?limit=-1",
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: device.ErrInvalidLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceMock := mocks.NewService(t)
+			h := &Handler{
+				service:      serviceMock,
+				legacyRoutes: true,
+			}
+			handler := h.InitRoutes()
+			page := device.Page{Devices: []device.Device{{SerialNum: "1234", Model: "hp", IP: "10.0.0.1"}}, NextCursor: "next"}
+			if tt.respErr == nil {
+				serviceMock.On("ListDevices", mock.Anything, tt.query).
+					Return(page, nil).Maybe()
+			} else {
+				serviceMock.On("ListDevices", mock.Anything, tt.query).
+					Return(device.Page{}, tt.respErr).Maybe()
+			}
+			req, err := http.NewRequest(tt.method, tt.target, nil)
+			require.NoError(t, err)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedError != nil {
+				actualError := Problem{}
+
+				err := json.Unmarshal(rr.Body.Bytes(), &actualError)
+				if err != nil {
+					assert.Fail(t, "error of unmarshalling error")
+				}
+
+				assert.Equal(t, tt.expectedCode, actualError.Status)
+				assert.Equal(t, tt.expectedError.Error(), actualError.Detail)
+				return
+			}
+			actualPage := device.Page{}
+			if tt.accept == "application/yaml" {
+				assert.Equal(t, "application/yaml", rr.Header().Get("Content-Type"))
+				require.NoError(t, yaml.Unmarshal(rr.Body.Bytes(), &actualPage))
+			} else {
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualPage))
 			}
+			assert.Equal(t, page, actualPage)
 		})
 	}
 }
@@ -369,7 +522,8 @@ func BenchmarkCreateDeviceHandle(b *testing.B) {
 	b.Run("Endpoint: /createDevice", func(b *testing.B) {
 
 		h := &Handler{
-			service: app.NewService(fakerepo.NewDeviceStorage()),
+			service:      app.NewService(fakerepo.NewDeviceStorage()),
+			legacyRoutes: true,
 		}
 		handler := h.InitRoutes()
 
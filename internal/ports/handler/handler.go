@@ -1,33 +1,92 @@
 package handler
 
 import (
+	"context"
 	"homework/internal/device"
+	"homework/internal/logger"
+	"homework/internal/ports/handler/validate"
+	"homework/internal/reqinfo"
+	"log/slog"
 	"net/http"
+	"strings"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.36.0 --name=Service
 type Service interface {
-	GetDevice(string) (device.Device, error)
-	CreateDevice(device.Device) error
-	DeleteDevice(string) error
-	UpdateDevice(device.Device) error
+	GetDevice(context.Context, string) (device.Device, error)
+	CreateDevice(context.Context, device.Device) error
+	DeleteDevice(context.Context, string) error
+	UpdateDevice(context.Context, device.Device) error
+	ListDevices(context.Context, device.ListQuery) (device.Page, error)
 }
 
 type Handler struct {
-	service Service
+	service      Service
+	legacyRoutes bool
+	validator    *validate.Registry
+	apiKeys      APIKeyStore
 }
 
-func NewHandler(service Service) *Handler {
-	return &Handler{
+type Option func(*Handler)
+
+// WithLegacyRoutes enables the RPC-style routes that read device fields from headers.
+func WithLegacyRoutes(enabled bool) Option {
+	return func(h *Handler) {
+		h.legacyRoutes = enabled
+	}
+}
+
+// WithValidator validates devices with per-model rules, DefaultRules are used without it.
+func WithValidator(validator *validate.Registry) Option {
+	return func(h *Handler) {
+		h.validator = validator
+	}
+}
+
+func NewHandler(service Service, opts ...Option) *Handler {
+	h := &Handler{
 		service: service,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handler) InitRoutes() http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/getDevice", h.handleGetDevice)
-	mux.HandleFunc("/createDevice", h.handleCreateDevice)
-	mux.HandleFunc("/deleteDevice", h.handleDeleteDevice)
-	mux.HandleFunc("/updateDevice", h.handleUpdateDevice)
+	handle := func(route string, f http.HandlerFunc) {
+		mux.HandleFunc(strings.TrimSuffix(route, "{id}"), withRoute(route, f))
+	}
+	handle(devicesPath, h.handleDevices)
+	handle(devicesPath+"/{id}", h.handleDevice)
+
+	if h.apiKeys != nil {
+		handle(apiKeysPath, h.handleAPIKeys)
+		handle(apiKeysPath+"/{id}", h.handleAPIKey)
+	}
+
+	if h.legacyRoutes {
+		handle("/getDevice", h.handleGetDevice)
+		handle("/createDevice", h.handleCreateDevice)
+		handle("/deleteDevice", h.handleDeleteDevice)
+		handle("/updateDevice", h.handleUpdateDevice)
+		handle("/listDevices", h.handleListDevices)
+	}
 	return mux
 }
+
+// withRoute records the route pattern for the request log line, metrics and trace.
+func withRoute(route string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqinfo.SetRoute(r.Context(), route)
+		f(w, r)
+	}
+}
+
+// logSerialNum adds the serial number of the device a request is about to the request log line.
+func logSerialNum(r *http.Request, serialNum string) {
+	if serialNum != "" {
+		logger.AddAttrs(r.Context(), slog.String("serialNum", serialNum))
+	}
+}
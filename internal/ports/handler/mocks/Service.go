@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	mock "github.com/stretchr/testify/mock"
 	"homework/internal/device"
 )
@@ -12,13 +14,13 @@ type Service struct {
 	mock.Mock
 }
 
-// CreateDevice provides a mock function with given fields: _a0
-func (_m *Service) CreateDevice(_a0 device.Device) error {
-	ret := _m.Called(_a0)
+// CreateDevice provides a mock function with given fields: _a0, _a1
+func (_m *Service) CreateDevice(_a0 context.Context, _a1 device.Device) error {
+	ret := _m.Called(_a0, _a1)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(device.Device) error); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, device.Device) error); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -26,13 +28,13 @@ func (_m *Service) CreateDevice(_a0 device.Device) error {
 	return r0
 }
 
-// DeleteDevice provides a mock function with given fields: _a0
-func (_m *Service) DeleteDevice(_a0 string) error {
-	ret := _m.Called(_a0)
+// DeleteDevice provides a mock function with given fields: _a0, _a1
+func (_m *Service) DeleteDevice(_a0 context.Context, _a1 string) error {
+	ret := _m.Called(_a0, _a1)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -40,23 +42,47 @@ func (_m *Service) DeleteDevice(_a0 string) error {
 	return r0
 }
 
-// GetDevice provides a mock function with given fields: _a0
-func (_m *Service) GetDevice(_a0 string) (device.Device, error) {
-	ret := _m.Called(_a0)
+// GetDevice provides a mock function with given fields: _a0, _a1
+func (_m *Service) GetDevice(_a0 context.Context, _a1 string) (device.Device, error) {
+	ret := _m.Called(_a0, _a1)
 
 	var r0 device.Device
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (device.Device, error)); ok {
-		return rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (device.Device, error)); ok {
+		return rf(_a0, _a1)
 	}
-	if rf, ok := ret.Get(0).(func(string) device.Device); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, string) device.Device); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		r0 = ret.Get(0).(device.Device)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDevices provides a mock function with given fields: _a0, _a1
+func (_m *Service) ListDevices(_a0 context.Context, _a1 device.ListQuery) (device.Page, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 device.Page
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, device.ListQuery) (device.Page, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, device.ListQuery) device.Page); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(device.Page)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, device.ListQuery) error); ok {
+		r1 = rf(_a0, _a1)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -64,13 +90,13 @@ func (_m *Service) GetDevice(_a0 string) (device.Device, error) {
 	return r0, r1
 }
 
-// UpdateDevice provides a mock function with given fields: _a0
-func (_m *Service) UpdateDevice(_a0 device.Device) error {
-	ret := _m.Called(_a0)
+// UpdateDevice provides a mock function with given fields: _a0, _a1
+func (_m *Service) UpdateDevice(_a0 context.Context, _a1 device.Device) error {
+	ret := _m.Called(_a0, _a1)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(device.Device) error); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, device.Device) error); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		r0 = ret.Error(0)
 	}
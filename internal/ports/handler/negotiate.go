@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"homework/internal/device"
+	"homework/internal/logger"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeYAML = "application/yaml"
+	mediaTypeCSV  = "text/csv"
+)
+
+var ErrNotAcceptable = errors.New("supported media types are application/json, application/yaml and text/csv")
+
+var csvHeader = []string{"serialNum", "model", "ip"}
+
+// mediaTypes maps accepted media types and wildcards to the type of the response.
+var mediaTypes = map[string]string{
+	"*/*":                mediaTypeJSON,
+	"application/*":      mediaTypeJSON,
+	"application/json":   mediaTypeJSON,
+	"application/yaml":   mediaTypeYAML,
+	"application/x-yaml": mediaTypeYAML,
+	"text/yaml":          mediaTypeYAML,
+	"text/x-yaml":        mediaTypeYAML,
+	"text/*":             mediaTypeCSV,
+	"text/csv":           mediaTypeCSV,
+}
+
+// negotiate picks the response media type from the Accept header, JSON is used when it is absent.
+func negotiate(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return mediaTypeJSON, true
+	}
+
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if resp, ok := mediaTypes[mediaType]; ok && q > 0 {
+			candidates = append(candidates, candidate{mediaType: resp, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].mediaType, true
+}
+
+// writeNegotiated writes a device or a page of devices in the media type requested by the client.
+func writeNegotiated(w http.ResponseWriter, r *http.Request, statusCode int, v any) {
+	mediaType, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeError(w, r, http.StatusNotAcceptable, ErrNotAcceptable)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	var (
+		data []byte
+		err  error
+	)
+	switch mediaType {
+	case mediaTypeYAML:
+		data, err = yaml.Marshal(v)
+	case mediaTypeCSV:
+		data, err = encodeCSV(w, v)
+	default:
+		writeJSON(w, r, statusCode, v)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(data); err != nil {
+		logger.FromContext(r.Context()).Error("failed to write response", slog.Any("error", err))
+	}
+}
+
+// encodeCSV writes devices as CSV rows with a header line.
+// The cursor of the next page doesn't fit into CSV, so it is returned in the X-Next-Cursor header.
+func encodeCSV(w http.ResponseWriter, v any) ([]byte, error) {
+	var devices []device.Device
+	switch v := v.(type) {
+	case device.Device:
+		devices = []device.Device{v}
+	case device.Page:
+		devices = v.Devices
+		if v.NextCursor != "" {
+			w.Header().Set("X-Next-Cursor", v.NextCursor)
+		}
+	default:
+		return nil, errors.New("value can't be encoded as CSV")
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, d := range devices {
+		if err := writer.Write([]string{d.SerialNum, d.Model, d.IP}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"homework/internal/device"
+	"homework/internal/ports/handler/mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+		ok       bool
+	}{
+		{"", mediaTypeJSON, true},
+		{"*/*", mediaTypeJSON, true},
+		{"application/json", mediaTypeJSON, true},
+		{"application/x-yaml", mediaTypeYAML, true},
+		{"text/csv", mediaTypeCSV, true},
+		{"text/csv;q=0.5, application/yaml", mediaTypeYAML, true},
+		{"text/html, text/csv;q=0.1", mediaTypeCSV, true},
+		{"application/json;q=0, text/csv;q=0.2", mediaTypeCSV, true},
+		{"text/html", "", false},
+		{"application/xml, image/png", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got, ok := negotiate(tt.accept)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestHandler_GetDeviceContentNegotiation(t *testing.T) {
+	d := device.Device{SerialNum: "1234", Model: "HP", IP: "111.111.111.111"}
+
+	tests := []struct {
+		name                string
+		accept              string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "JSON by default",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"serialNum":"1234","model":"HP","ip":"111.111.111.111"}`,
+		},
+		{
+			name:                "YAML",
+			accept:              "application/yaml",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/yaml",
+			expectedBody:        "serialNum: \"1234\"\nmodel: HP\nip: 111.111.111.111\n",
+		},
+		{
+			name:                "CSV",
+			accept:              "text/csv",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedBody:        "serialNum,model,ip\n1234,HP,111.111.111.111\n",
+		},
+		{
+			name:         "Not acceptable",
+			accept:       "text/html",
+			expectedCode: http.StatusNotAcceptable,
+		},
+	}
+	for _, tt := range tests {
+		for _, target := range []string{"/getDevice", "/v1/devices/1234"} {
+			t.Run(tt.name+" "+target, func(t *testing.T) {
+				serviceMock := mocks.NewService(t)
+				serviceMock.On("GetDevice", mock.Anything, d.SerialNum).Return(d, nil)
+				handler := NewHandler(serviceMock, WithLegacyRoutes(true)).InitRoutes()
+
+				req, err := http.NewRequest("GET", target, nil)
+				require.NoError(t, err)
+				req.Header.Set("serialNum", d.SerialNum)
+				req.Header.Set("Accept", tt.accept)
+
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				require.Equal(t, tt.expectedCode, rr.Code)
+				if tt.expectedCode == http.StatusOK {
+					assert.Equal(t, tt.expectedContentType, rr.Header().Get("Content-Type"))
+					assert.Equal(t, tt.expectedBody, rr.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestHandler_ListDevicesCSV(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+	serviceMock.On("ListDevices", mock.Anything, device.ListQuery{}).
+		Return(device.Page{
+			Devices:    []device.Device{{SerialNum: "1234", Model: "HP", IP: "1.1.1.1"}, {SerialNum: "1235", Model: "HP, Inc", IP: "1.1.1.2"}},
+			NextCursor: "next",
+		}, nil)
+	handler := NewHandler(serviceMock).InitRoutes()
+
+	req, err := http.NewRequest("GET", "/v1/devices", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/csv")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "next", rr.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, "serialNum,model,ip\n1234,HP,1.1.1.1\n1235,\"HP, Inc\",1.1.1.2\n", rr.Body.String())
+}
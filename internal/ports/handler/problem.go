@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"homework/internal/problem"
+	"net/http"
+)
+
+const mediaTypeProblem = problem.MediaType
+
+// Problem is an RFC 7807 problem details response body.
+type Problem = problem.Problem
+
+// errorStatus maps domain errors returned by the service to HTTP status codes.
+func errorStatus(err error) int {
+	return problem.Status(err)
+}
+
+// writeError writes err as a problem response.
+// Only messages of domain errors and of client errors are shown, the rest are logged.
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	problem.Write(w, r, statusCode, err)
+}
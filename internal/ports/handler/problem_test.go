@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/app"
+	"homework/internal/device"
+	"homework/internal/ports/handler/validate"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected Problem
+	}{
+		{
+			name: "Not found",
+			err:  app.NewError(app.ErrNotFound, "device_not_found", "there is no such device"),
+			expected: Problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "there is no such device", Code: "device_not_found"},
+		},
+		{
+			name: "Conflict",
+			err:  app.NewError(app.ErrConflict, "device_already_exists", "such device is already in database"),
+			expected: Problem{Type: "about:blank", Title: "Conflict", Status: http.StatusConflict,
+				Detail: "such device is already in database", Code: "device_already_exists"},
+		},
+		{
+			name: "Validation",
+			err:  validate.ValidateDevice(device.Device{SerialNum: "1@", IP: "invalidip"}),
+			expected: Problem{Type: "about:blank", Title: "Unprocessable Entity", Status: http.StatusUnprocessableEntity,
+				Detail: "serialNum: should be at least 3 characters long; serialNum: should contain only digits or letters; model: is required; ip: is not a valid IP address",
+				Code:   "validation_failed",
+				Errors: []app.FieldError{
+					{Field: "serialNum", Rule: validate.RuleMinLength, Message: "should be at least 3 characters long"},
+					{Field: "serialNum", Rule: validate.RuleCharset, Message: "should contain only digits or letters"},
+					{Field: "model", Rule: validate.RuleRequired, Message: "is required"},
+					{Field: "ip", Rule: validate.RuleIP, Message: "is not a valid IP address"},
+				}},
+		},
+		{
+			name: "Unavailable hides the cause",
+			err:  app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to get device", errors.New("disk I/O error")),
+			expected: Problem{Type: "about:blank", Title: "Service Unavailable", Status: http.StatusServiceUnavailable,
+				Detail: "failed to get device", Code: "storage_unavailable"},
+		},
+		{
+			name: "Forbidden",
+			err:  app.NewError(app.ErrForbidden, "forbidden", "not allowed to perform devices:delete"),
+			expected: Problem{Type: "about:blank", Title: "Forbidden", Status: http.StatusForbidden,
+				Detail: "not allowed to perform devices:delete", Code: "forbidden"},
+		},
+		{
+			name: "Unknown error hides the message",
+			err:  errors.New("connection refused"),
+			expected: Problem{Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError,
+				Code: "internal_server_error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			writeError(rr, httptest.NewRequest("GET", "/v1/devices", nil), errorStatus(tt.err), tt.err)
+
+			require.Equal(t, tt.expected.Status, rr.Code)
+			assert.Equal(t, mediaTypeProblem, rr.Header().Get("Content-Type"))
+
+			actual := Problem{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actual))
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
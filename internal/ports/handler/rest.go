@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"homework/internal/device"
+	"net/http"
+	"strings"
+)
+
+const devicesPath = "/v1/devices"
+
+var (
+	ErrInvalidBody        = errors.New("request body is not a valid device JSON")
+	ErrSerialNumMismatch  = errors.New("serialNum in body does not match the one in path")
+	ErrResourceNotFound   = errors.New("resource not found")
+	ErrMethodNotSupported = errors.New("method is not allowed for this resource")
+)
+
+// devicePatch holds the fields of a PATCH request, nil fields are left unchanged.
+type devicePatch struct {
+	Model *string `json:"model"`
+	IP    *string `json:"ip"`
+}
+
+// handleDevices serves the device collection: GET lists devices, POST creates one.
+func (h *Handler) handleDevices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query, err := parseListQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		page, err := h.service.ListDevices(r.Context(), query)
+		if err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		writeNegotiated(w, r, http.StatusOK, page)
+	case http.MethodPost:
+		var d device.Device
+		if err := decodeBody(r, &d); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err := h.validator.ValidateDevice(d); err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		logSerialNum(r, d.SerialNum)
+		if err := h.service.CreateDevice(r.Context(), d); err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		w.Header().Set("Location", devicesPath+"/"+d.SerialNum)
+		writeJSON(w, r, http.StatusCreated, d)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleDevice serves a single device addressed by /v1/devices/{serialNum}.
+func (h *Handler) handleDevice(w http.ResponseWriter, r *http.Request) {
+	serialNum := strings.TrimPrefix(r.URL.Path, devicesPath+"/")
+	if serialNum == "" || strings.Contains(serialNum, "/") {
+		writeError(w, r, http.StatusNotFound, ErrResourceNotFound)
+		return
+	}
+	logSerialNum(r, serialNum)
+	if err := h.validator.IsValidSerialNum(serialNum); err != nil {
+		writeError(w, r, errorStatus(err), err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		d, err := h.service.GetDevice(r.Context(), serialNum)
+		if err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		writeNegotiated(w, r, http.StatusOK, d)
+	case http.MethodPut:
+		var d device.Device
+		if err := decodeBody(r, &d); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if d.SerialNum == "" {
+			d.SerialNum = serialNum
+		}
+		if d.SerialNum != serialNum {
+			writeError(w, r, http.StatusBadRequest, ErrSerialNumMismatch)
+			return
+		}
+		h.updateDevice(w, r, d)
+	case http.MethodPatch:
+		var patch devicePatch
+		if err := decodeBody(r, &patch); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		d, err := h.service.GetDevice(r.Context(), serialNum)
+		if err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		if patch.Model != nil {
+			d.Model = *patch.Model
+		}
+		if patch.IP != nil {
+			d.IP = *patch.IP
+		}
+		h.updateDevice(w, r, d)
+	case http.MethodDelete:
+		if err := h.service.DeleteDevice(r.Context(), serialNum); err != nil {
+			writeError(w, r, errorStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (h *Handler) updateDevice(w http.ResponseWriter, r *http.Request, d device.Device) {
+	if err := h.validator.ValidateDevice(d); err != nil {
+		writeError(w, r, errorStatus(err), err)
+		return
+	}
+	if err := h.service.UpdateDevice(r.Context(), d); err != nil {
+		writeError(w, r, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, d)
+}
+
+func decodeBody(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return ErrInvalidBody
+	}
+	return nil
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, r, http.StatusMethodNotAllowed, ErrMethodNotSupported)
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"homework/internal/adapters/fakerepo"
+	"homework/internal/app"
+	"homework/internal/config"
+	"homework/internal/device"
+	"homework/internal/ports/handler/mocks"
+	"homework/internal/ports/handler/validate"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHandler_REST(t *testing.T) {
+	existing := device.Device{SerialNum: "1234", Model: "hp", IP: "121.121.212.121"}
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		setup          func(s *mocks.Service)
+		expectedCode   int
+		expectedBody   any
+		expectedError  error
+		expectedHeader map[string]string
+	}{
+		{
+			name:   "List",
+			method: "GET",
+			target: "/v1/devices?model=hp",
+			setup: func(s *mocks.Service) {
+				s.On("ListDevices", mock.Anything, device.ListQuery{Model: "hp"}).
+					Return(device.Page{Devices: []device.Device{existing}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: &device.Page{Devices: []device.Device{existing}},
+		},
+		{
+			name:   "Create",
+			method: "POST",
+			target: "/v1/devices",
+			body:   `{"serialNum":"1234","model":"hp","ip":"121.121.212.121"}`,
+			setup: func(s *mocks.Service) {
+				s.On("CreateDevice", mock.Anything, existing).Return(nil)
+			},
+			expectedCode:   http.StatusCreated,
+			expectedBody:   &existing,
+			expectedHeader: map[string]string{"Location": "/v1/devices/1234"},
+		},
+		{
+			name:   "Create duplicate",
+			method: "POST",
+			target: "/v1/devices",
+			body:   `{"serialNum":"1234","model":"hp","ip":"121.121.212.121"}`,
+			setup: func(s *mocks.Service) {
+				s.On("CreateDevice", mock.Anything, existing).Return(fakerepo.ErrDeviceAlreadyExists)
+			},
+			expectedCode:  http.StatusConflict,
+			expectedError: fakerepo.ErrDeviceAlreadyExists,
+		},
+		{
+			name:          "Create invalid JSON",
+			method:        "POST",
+			target:        "/v1/devices",
+			body:          `{"serialNum":`,
+			expectedCode:  http.StatusBadRequest,
+			expectedError: ErrInvalidBody,
+		},
+		{
+			name:           "Collection method not allowed",
+			method:         "DELETE",
+			target:         "/v1/devices",
+			expectedCode:   http.StatusMethodNotAllowed,
+			expectedError:  ErrMethodNotSupported,
+			expectedHeader: map[string]string{"Allow": "GET, POST"},
+		},
+		{
+			name:   "Get",
+			method: "GET",
+			target: "/v1/devices/1234",
+			setup: func(s *mocks.Service) {
+				s.On("GetDevice", mock.Anything, "1234").Return(existing, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: &existing,
+		},
+		{
+			name:   "Get missing",
+			method: "GET",
+			target: "/v1/devices/1234",
+			setup: func(s *mocks.Service) {
+				s.On("GetDevice", mock.Anything, "1234").Return(device.Device{}, fakerepo.ErrNoSuchDevice)
+			},
+			expectedCode:  http.StatusNotFound,
+			expectedError: fakerepo.ErrNoSuchDevice,
+		},
+		{
+			name:   "Put",
+			method: "PUT",
+			target: "/v1/devices/1234",
+			body:   `{"model":"hp","ip":"121.121.212.121"}`,
+			setup: func(s *mocks.Service) {
+				s.On("UpdateDevice", mock.Anything, existing).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: &existing,
+		},
+		{
+			name:          "Put serialNum mismatch",
+			method:        "PUT",
+			target:        "/v1/devices/1234",
+			body:          `{"serialNum":"4321","model":"hp","ip":"121.121.212.121"}`,
+			expectedCode:  http.StatusBadRequest,
+			expectedError: ErrSerialNumMismatch,
+		},
+		{
+			name:   "Patch",
+			method: "PATCH",
+			target: "/v1/devices/1234",
+			body:   `{"ip":"10.0.0.1"}`,
+			setup: func(s *mocks.Service) {
+				s.On("GetDevice", mock.Anything, "1234").Return(existing, nil)
+				s.On("UpdateDevice", mock.Anything, device.Device{SerialNum: "1234", Model: "hp", IP: "10.0.0.1"}).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: &device.Device{SerialNum: "1234", Model: "hp", IP: "10.0.0.1"},
+		},
+		{
+			name:   "Patch missing",
+			method: "PATCH",
+			target: "/v1/devices/1234",
+			body:   `{"ip":"10.0.0.1"}`,
+			setup: func(s *mocks.Service) {
+				s.On("GetDevice", mock.Anything, "1234").Return(device.Device{}, fakerepo.ErrNoSuchDevice)
+			},
+			expectedCode:  http.StatusNotFound,
+			expectedError: fakerepo.ErrNoSuchDevice,
+		},
+		{
+			name:   "Delete",
+			method: "DELETE",
+			target: "/v1/devices/1234",
+			setup: func(s *mocks.Service) {
+				s.On("DeleteDevice", mock.Anything, "1234").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:   "Delete missing",
+			method: "DELETE",
+			target: "/v1/devices/1234",
+			setup: func(s *mocks.Service) {
+				s.On("DeleteDevice", mock.Anything, "1234").Return(fakerepo.ErrNoSuchDevice)
+			},
+			expectedCode:  http.StatusNotFound,
+			expectedError: fakerepo.ErrNoSuchDevice,
+		},
+		{
+			name:           "Item method not allowed",
+			method:         "POST",
+			target:         "/v1/devices/1234",
+			expectedCode:   http.StatusMethodNotAllowed,
+			expectedError:  ErrMethodNotSupported,
+			expectedHeader: map[string]string{"Allow": "GET, PUT, PATCH, DELETE"},
+		},
+		{
+			name:          "Nested path",
+			method:        "GET",
+			target:        "/v1/devices/1234/ports",
+			expectedCode:  http.StatusNotFound,
+			expectedError: ErrResourceNotFound,
+		},
+		{
+			name:         "Legacy routes disabled",
+			method:       "GET",
+			target:       "/getDevice",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceMock := mocks.NewService(t)
+			if tt.setup != nil {
+				tt.setup(serviceMock)
+			}
+			handler := NewHandler(serviceMock).InitRoutes()
+
+			req, err := http.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedCode, rr.Code)
+			for key, value := range tt.expectedHeader {
+				assert.Equal(t, value, rr.Header().Get(key))
+			}
+			if tt.expectedError != nil {
+				actualError := Problem{}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualError))
+				assert.Equal(t, mediaTypeProblem, rr.Header().Get("Content-Type"))
+				assert.Equal(t, tt.expectedError.Error(), actualError.Detail)
+			}
+			if tt.expectedBody != nil {
+				actualBody := reflect.New(reflect.TypeOf(tt.expectedBody).Elem()).Interface()
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), actualBody))
+				assert.Equal(t, tt.expectedBody, actualBody)
+			}
+		})
+	}
+}
+
+func TestHandler_LegacyRoutesEnabled(t *testing.T) {
+	serviceMock := mocks.NewService(t)
+	serviceMock.On("GetDevice", mock.Anything, mock.Anything).Return(device.Device{}, nil)
+	handler := NewHandler(serviceMock, WithLegacyRoutes(true)).InitRoutes()
+
+	req, err := http.NewRequest("GET", "/getDevice", nil)
+	require.NoError(t, err)
+	req.Header.Set("serialNum", "1234")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestHandler_ModelValidationRules(t *testing.T) {
+	registry := validate.NewRegistry()
+	rules, err := validate.RulesFromConfig(config.ModelRules{Subnets: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+	registry.Register("hp", rules)
+	handler := NewHandler(mocks.NewService(t), WithValidator(registry)).InitRoutes()
+
+	req, err := http.NewRequest("POST", "/v1/devices", strings.NewReader(`{"serialNum":"1234","model":"hp","ip":"192.168.1.1"}`))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	actualError := Problem{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualError))
+	assert.Equal(t, []app.FieldError{{Field: "ip", Rule: validate.RuleSubnet, Message: "should belong to 10.0.0.0/8"}}, actualError.Errors)
+}
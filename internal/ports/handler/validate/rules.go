@@ -0,0 +1,158 @@
+package validate
+
+import (
+	"fmt"
+	"homework/internal/app"
+	"homework/internal/config"
+	"homework/internal/device"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IPFamily is an address family allowed for device IPs.
+type IPFamily string
+
+const (
+	IPv4 IPFamily = "ipv4"
+	IPv6 IPFamily = "ipv6"
+)
+
+// Rules describe how devices of a model are validated.
+type Rules struct {
+	// SerialPattern replaces the default digits-or-letters check when set.
+	SerialPattern *regexp.Regexp
+	// serialPattern is the configured pattern, reported instead of the anchored SerialPattern.
+	serialPattern   string
+	SerialMinLength int
+	// SerialMaxLength of 0 means no upper bound.
+	SerialMaxLength int
+	// IPFamilies of nil allows any family.
+	IPFamilies []IPFamily
+	// Subnets of nil allows any address.
+	Subnets []*net.IPNet
+}
+
+// DefaultRules apply to models without registered rules.
+var DefaultRules = Rules{SerialMinLength: minSerialNumLength}
+
+// RulesFromConfig compiles rules loaded from the config, zero fields keep the defaults.
+// The serial pattern is anchored, so it has to match the whole serial number.
+func RulesFromConfig(cfg config.ModelRules) (Rules, error) {
+	rules := DefaultRules
+	if cfg.SerialPattern != "" {
+		pattern, err := regexp.Compile(`^(?:` + cfg.SerialPattern + `)$`)
+		if err != nil {
+			return Rules{}, fmt.Errorf("invalid serial_pattern: %w", err)
+		}
+		rules.SerialPattern = pattern
+		rules.serialPattern = cfg.SerialPattern
+	}
+	if cfg.SerialMinLength > 0 {
+		rules.SerialMinLength = cfg.SerialMinLength
+	}
+	if cfg.SerialMaxLength > 0 {
+		if cfg.SerialMaxLength < rules.SerialMinLength {
+			return Rules{}, fmt.Errorf("serial_max_length %d is less than serial_min_length %d",
+				cfg.SerialMaxLength, rules.SerialMinLength)
+		}
+		rules.SerialMaxLength = cfg.SerialMaxLength
+	}
+	for _, family := range cfg.IPFamilies {
+		switch f := IPFamily(strings.ToLower(family)); f {
+		case IPv4, IPv6:
+			rules.IPFamilies = append(rules.IPFamilies, f)
+		default:
+			return Rules{}, fmt.Errorf("unknown ip family %q", family)
+		}
+	}
+	for _, subnet := range cfg.Subnets {
+		_, ipNet, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return Rules{}, fmt.Errorf("invalid subnet %q: %w", subnet, err)
+		}
+		rules.Subnets = append(rules.Subnets, ipNet)
+	}
+	return rules, nil
+}
+
+// Registry holds validation rules per Device.Model.
+// A nil Registry applies DefaultRules to every model.
+type Registry struct {
+	mu     sync.RWMutex
+	models map[string]Rules
+}
+
+func NewRegistry() *Registry {
+	return &Registry{models: make(map[string]Rules)}
+}
+
+// NewRegistryFromConfig builds a registry from the validation section of the config.
+func NewRegistryFromConfig(cfg config.Validation) (*Registry, error) {
+	r := NewRegistry()
+	for model, modelCfg := range cfg.Models {
+		rules, err := RulesFromConfig(modelCfg)
+		if err != nil {
+			return nil, fmt.Errorf("validation rules of model %q: %w", model, err)
+		}
+		r.Register(model, rules)
+	}
+	return r, nil
+}
+
+// Register sets the rules of the model, replacing previously registered ones.
+func (r *Registry) Register(model string, rules Rules) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[model] = rules
+}
+
+// Rules returns the rules of the model or DefaultRules if none are registered.
+func (r *Registry) Rules(model string) Rules {
+	if r == nil {
+		return DefaultRules
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rules, ok := r.models[model]; ok {
+		return rules
+	}
+	return DefaultRules
+}
+
+// ValidateDevice checks the device against the rules of its model and reports every violation at once.
+func (r *Registry) ValidateDevice(device device.Device) error {
+	if violations := r.Violations(device); len(violations) > 0 {
+		return app.NewValidationError(violations)
+	}
+	return nil
+}
+
+// IsValidSerialNum checks a serial number whose model is unknown, e.g. one taken from the URL.
+// It is valid if it satisfies the default rules or the rules of any registered model,
+// otherwise every violation of the default rules is reported.
+func (r *Registry) IsValidSerialNum(serialNum string) error {
+	violations := serialNumViolations(DefaultRules, serialNum)
+	if len(violations) == 0 {
+		return nil
+	}
+	if r != nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		for _, rules := range r.models {
+			if len(serialNumViolations(rules, serialNum)) == 0 {
+				return nil
+			}
+		}
+	}
+	if len(violations) == 1 {
+		if violations[0].Rule == RuleMinLength {
+			return ErrSerialNumLength
+		}
+		return ErrSerialNumChar
+	}
+	err := app.NewValidationError(violations)
+	err.Code = "invalid_serial_num"
+	return err
+}
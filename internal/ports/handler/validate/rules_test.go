@@ -0,0 +1,161 @@
+package validate
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/app"
+	"homework/internal/config"
+	"homework/internal/device"
+	"testing"
+)
+
+func TestRegistry_ValidateDevice(t *testing.T) {
+	registry, err := NewRegistryFromConfig(config.Validation{Models: map[string]config.ModelRules{
+		"cisco": {
+			SerialPattern:   "^FOC[0-9A-Z]+-[0-9]+$",
+			SerialMaxLength: 12,
+			IPFamilies:      []string{"ipv4"},
+			Subnets:         []string{"10.0.0.0/8"},
+		},
+		"arista": {
+			SerialPattern: "[A-Z]{3}[0-9]+",
+		},
+		"juniper": {
+			SerialMinLength: 5,
+			IPFamilies:      []string{"ipv6"},
+		},
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		device   device.Device
+		expected []app.FieldError
+	}{
+		{
+			name:   "Valid by model rules",
+			device: device.Device{SerialNum: "FOC1234-1", Model: "cisco", IP: "10.1.2.3"},
+		},
+		{
+			name:   "Unregistered model uses default rules",
+			device: device.Device{SerialNum: "FOC1234-1", Model: "hp", IP: "10.1.2.3"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RuleCharset, Message: "should contain only digits or letters"},
+			},
+		},
+		{
+			name:   "Serial pattern",
+			device: device.Device{SerialNum: "ABC1234", Model: "cisco", IP: "10.1.2.3"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RulePattern, Message: "should match ^FOC[0-9A-Z]+-[0-9]+$"},
+			},
+		},
+		{
+			name:   "Serial max length",
+			device: device.Device{SerialNum: "FOC123456-789", Model: "cisco", IP: "10.1.2.3"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RuleMaxLength, Message: "should be at most 12 characters long"},
+			},
+		},
+		{
+			name:   "Serial min length",
+			device: device.Device{SerialNum: "1234", Model: "juniper", IP: "2001:db8::1"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RuleMinLength, Message: "should be at least 5 characters long"},
+			},
+		},
+		{
+			name:   "IP family",
+			device: device.Device{SerialNum: "12345", Model: "juniper", IP: "10.1.2.3"},
+			expected: []app.FieldError{
+				{Field: "ip", Rule: RuleIPFamily, Message: "should be an address of ipv6"},
+			},
+		},
+		{
+			name:   "Subnet",
+			device: device.Device{SerialNum: "FOC1234-1", Model: "cisco", IP: "192.168.1.1"},
+			expected: []app.FieldError{
+				{Field: "ip", Rule: RuleSubnet, Message: "should belong to 10.0.0.0/8"},
+			},
+		},
+		{
+			name:   "Unanchored pattern matches the whole serial",
+			device: device.Device{SerialNum: "JPE12345", Model: "arista", IP: "10.1.2.3"},
+		},
+		{
+			name:   "Invalid leading characters",
+			device: device.Device{SerialNum: "--JPE12345", Model: "arista", IP: "10.1.2.3"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RulePattern, Message: "should match [A-Z]{3}[0-9]+"},
+			},
+		},
+		{
+			name:   "Invalid trailing characters",
+			device: device.Device{SerialNum: "JPE12345@@", Model: "arista", IP: "10.1.2.3"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RulePattern, Message: "should match [A-Z]{3}[0-9]+"},
+			},
+		},
+		{
+			name:   "Several rules of a field",
+			device: device.Device{SerialNum: "ABC123456-789", Model: "cisco", IP: "2001:db8::1"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RuleMaxLength, Message: "should be at most 12 characters long"},
+				{Field: "serialNum", Rule: RulePattern, Message: "should match ^FOC[0-9A-Z]+-[0-9]+$"},
+				{Field: "ip", Rule: RuleIPFamily, Message: "should be an address of ipv4"},
+				{Field: "ip", Rule: RuleSubnet, Message: "should belong to 10.0.0.0/8"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.ValidateDevice(tt.device)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var appErr *app.Error
+			if assert.True(t, errors.As(err, &appErr)) {
+				assert.Equal(t, tt.expected, appErr.Fields)
+			}
+		})
+	}
+}
+
+func TestRegistry_IsValidSerialNum(t *testing.T) {
+	registry := NewRegistry()
+	rules, err := RulesFromConfig(config.ModelRules{SerialPattern: "^FOC[0-9]+-[0-9]+$"})
+	require.NoError(t, err)
+	registry.Register("cisco", rules)
+
+	assert.NoError(t, registry.IsValidSerialNum("abc123"))
+	assert.NoError(t, registry.IsValidSerialNum("FOC123-4"))
+	assert.Equal(t, ErrSerialNumChar, registry.IsValidSerialNum("XYZ-4"))
+	assert.Equal(t, ErrSerialNumLength, registry.IsValidSerialNum("ab"))
+
+	err = registry.IsValidSerialNum("a@")
+	var appErr *app.Error
+	if assert.True(t, errors.As(err, &appErr)) {
+		assert.Equal(t, "invalid_serial_num", appErr.Code)
+		assert.Equal(t, []app.FieldError{serialNumLength, serialNumChar}, appErr.Fields)
+	}
+}
+
+func TestNewRegistryFromConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules config.ModelRules
+	}{
+		{name: "Pattern", rules: config.ModelRules{SerialPattern: "("}},
+		{name: "Length bounds", rules: config.ModelRules{SerialMinLength: 10, SerialMaxLength: 5}},
+		{name: "IP family", rules: config.ModelRules{IPFamilies: []string{"ipx"}}},
+		{name: "Subnet", rules: config.ModelRules{Subnets: []string{"10.0.0.0"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRegistryFromConfig(config.Validation{Models: map[string]config.ModelRules{"hp": tt.rules}})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,42 +1,152 @@
 package validate
 
 import (
-	"errors"
+	"fmt"
+	"homework/internal/app"
 	"homework/internal/device"
 	"net"
 	"regexp"
+	"strings"
 )
 
+// Names of validation rules reported in app.FieldError.
+const (
+	RuleRequired  = "required"
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleCharset   = "charset"
+	RulePattern   = "pattern"
+	RuleIP        = "ip"
+	RuleIPFamily  = "ip_family"
+	RuleSubnet    = "subnet"
+)
+
+const minSerialNumLength = 3
+
 var (
-	rexegpSerialNum     = "^[0-9a-zA-Z]+$"
-	ErrSerialNumLength  = errors.New("serialNum should be at least 3 characters long")
-	ErrSerialNumChar    = errors.New("serialNum should contain only digits or letters")
-	ErrDeviceEmptyField = errors.New("field cannot be empty")
-	ErrDeviceInvalidIP  = errors.New("IP field is in wrong format")
+	rexegpSerialNum = regexp.MustCompile("^[0-9a-zA-Z]+$")
+
+	serialNumLength = app.FieldError{Field: "serialNum", Rule: RuleMinLength, Message: "should be at least 3 characters long"}
+	serialNumChar   = app.FieldError{Field: "serialNum", Rule: RuleCharset, Message: "should contain only digits or letters"}
+
+	ErrSerialNumLength = app.NewFieldError("invalid_serial_num", serialNumLength)
+	ErrSerialNumChar   = app.NewFieldError("invalid_serial_num", serialNumChar)
 )
 
+// ValidateDevice checks all fields of the device against DefaultRules and reports every violation at once.
 func ValidateDevice(device device.Device) error {
-	if device.SerialNum == "" || device.Model == "" || device.IP == "" {
-		return ErrDeviceEmptyField
+	var r *Registry
+	return r.ValidateDevice(device)
+}
+
+// IsValidSerialNum should be at least 3 characters in length and contain only digits or letters.
+func IsValidSerialNum(serialNum string) error {
+	var r *Registry
+	return r.IsValidSerialNum(serialNum)
+}
+
+// Violations returns every rule the device breaks, in the order of its fields.
+func (r *Registry) Violations(device device.Device) []app.FieldError {
+	rules := r.Rules(device.Model)
+
+	var violations []app.FieldError
+	if device.SerialNum == "" {
+		violations = append(violations, required("serialNum"))
+	} else {
+		violations = append(violations, serialNumViolations(rules, device.SerialNum)...)
 	}
-	if err := IsValidSerialNum(device.SerialNum); err != nil {
-		return err
+	if device.Model == "" {
+		violations = append(violations, required("model"))
 	}
-	if net.ParseIP(device.IP) == nil {
-		return ErrDeviceInvalidIP
+	if device.IP == "" {
+		violations = append(violations, required("ip"))
+	} else {
+		violations = append(violations, ipViolations(rules, device.IP)...)
 	}
-	return nil
+	return violations
 }
 
-// IsValidSerialNum should be at least 3 characters in length and contain only digits or letters.
-func IsValidSerialNum(serialNum string) error {
-	if len(serialNum) < 3 {
-		return ErrSerialNumLength
+func serialNumViolations(rules Rules, serialNum string) []app.FieldError {
+	var violations []app.FieldError
+	if len(serialNum) < rules.SerialMinLength {
+		if rules.SerialMinLength == minSerialNumLength {
+			violations = append(violations, serialNumLength)
+		} else {
+			violations = append(violations, app.FieldError{Field: "serialNum", Rule: RuleMinLength,
+				Message: fmt.Sprintf("should be at least %d characters long", rules.SerialMinLength)})
+		}
 	}
+	if rules.SerialMaxLength > 0 && len(serialNum) > rules.SerialMaxLength {
+		violations = append(violations, app.FieldError{Field: "serialNum", Rule: RuleMaxLength,
+			Message: fmt.Sprintf("should be at most %d characters long", rules.SerialMaxLength)})
+	}
+	if serialNum == "" {
+		// there are no characters to check
+		return violations
+	}
+	if rules.SerialPattern != nil {
+		if !rules.SerialPattern.MatchString(serialNum) {
+			pattern := rules.serialPattern
+			if pattern == "" {
+				pattern = rules.SerialPattern.String()
+			}
+			violations = append(violations, app.FieldError{Field: "serialNum", Rule: RulePattern,
+				Message: "should match " + pattern})
+		}
+	} else if !rexegpSerialNum.MatchString(serialNum) {
+		violations = append(violations, serialNumChar)
+	}
+	return violations
+}
+
+// ipViolations reports only RuleIP for unparsable addresses, the other rules need an address.
+func ipViolations(rules Rules, rawIP string) []app.FieldError {
+	ip := net.ParseIP(rawIP)
+	if ip == nil {
+		return []app.FieldError{{Field: "ip", Rule: RuleIP, Message: "is not a valid IP address"}}
+	}
+	var violations []app.FieldError
+	if len(rules.IPFamilies) > 0 && !containsFamily(rules.IPFamilies, ip) {
+		families := make([]string, 0, len(rules.IPFamilies))
+		for _, f := range rules.IPFamilies {
+			families = append(families, string(f))
+		}
+		violations = append(violations, app.FieldError{Field: "ip", Rule: RuleIPFamily,
+			Message: "should be an address of " + strings.Join(families, " or ")})
+	}
+	if len(rules.Subnets) > 0 && !containsIP(rules.Subnets, ip) {
+		subnets := make([]string, 0, len(rules.Subnets))
+		for _, s := range rules.Subnets {
+			subnets = append(subnets, s.String())
+		}
+		violations = append(violations, app.FieldError{Field: "ip", Rule: RuleSubnet,
+			Message: "should belong to " + strings.Join(subnets, ", ")})
+	}
+	return violations
+}
 
-	if !(regexp.MustCompile(rexegpSerialNum).MatchString(serialNum)) {
-		return ErrSerialNumChar
+func containsFamily(families []IPFamily, ip net.IP) bool {
+	family := IPv6
+	if ip.To4() != nil {
+		family = IPv4
 	}
-	return nil
+	for _, f := range families {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(subnets []*net.IPNet, ip net.IP) bool {
+	for _, s := range subnets {
+		if s.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
 
+func required(field string) app.FieldError {
+	return app.FieldError{Field: field, Rule: RuleRequired, Message: "is required"}
 }
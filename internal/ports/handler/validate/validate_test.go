@@ -1,8 +1,9 @@
 package validate
 
 import (
-	"fmt"
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"homework/internal/app"
 	"homework/internal/device"
 	"net"
 	"regexp"
@@ -13,7 +14,7 @@ func TestValidateDevice(t *testing.T) {
 	tests := []struct {
 		name     string
 		device   device.Device
-		expected error
+		expected []app.FieldError
 	}{
 		{
 			name:     "Valid Device",
@@ -21,31 +22,66 @@ func TestValidateDevice(t *testing.T) {
 			expected: nil,
 		},
 		{
-			name:     "Empty SerialNum",
-			device:   device.Device{SerialNum: "", Model: "Model2", IP: "192.168.1.2"},
-			expected: fmt.Errorf("field cannot be empty"),
+			name:   "Empty SerialNum",
+			device: device.Device{SerialNum: "", Model: "Model2", IP: "192.168.1.2"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RuleRequired, Message: "is required"},
+			},
 		},
 		{
-			name:     "Empty Model",
-			device:   device.Device{SerialNum: "67890", Model: "", IP: "192.168.1.3"},
-			expected: fmt.Errorf("field cannot be empty"),
+			name:   "Empty Model",
+			device: device.Device{SerialNum: "67890", Model: "", IP: "192.168.1.3"},
+			expected: []app.FieldError{
+				{Field: "model", Rule: RuleRequired, Message: "is required"},
+			},
 		},
 		{
-			name:     "Empty IP",
-			device:   device.Device{SerialNum: "54321", Model: "Model3", IP: ""},
-			expected: fmt.Errorf("field cannot be empty"),
+			name:   "Empty IP",
+			device: device.Device{SerialNum: "54321", Model: "Model3", IP: ""},
+			expected: []app.FieldError{
+				{Field: "ip", Rule: RuleRequired, Message: "is required"},
+			},
 		},
 		{
-			name:     "Invalid IP Format",
-			device:   device.Device{SerialNum: "13579", Model: "Model4", IP: "invalidip"},
-			expected: fmt.Errorf("IP field is in wrong format"),
+			name:   "Invalid IP Format",
+			device: device.Device{SerialNum: "13579", Model: "Model4", IP: "invalidip"},
+			expected: []app.FieldError{
+				{Field: "ip", Rule: RuleIP, Message: "is not a valid IP address"},
+			},
+		},
+		{
+			name:   "All fields invalid",
+			device: device.Device{SerialNum: "1@", Model: "", IP: "invalidip"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RuleMinLength, Message: "should be at least 3 characters long"},
+				{Field: "serialNum", Rule: RuleCharset, Message: "should contain only digits or letters"},
+				{Field: "model", Rule: RuleRequired, Message: "is required"},
+				{Field: "ip", Rule: RuleIP, Message: "is not a valid IP address"},
+			},
+		},
+		{
+			name:   "Invalid serialNum characters and empty model",
+			device: device.Device{SerialNum: "123@", Model: "", IP: "192.168.1.4"},
+			expected: []app.FieldError{
+				{Field: "serialNum", Rule: RuleCharset, Message: "should contain only digits or letters"},
+				{Field: "model", Rule: RuleRequired, Message: "is required"},
+			},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			err := ValidateDevice(test.device)
-			assert.Equal(t, test.expected, err)
+			if test.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var appErr *app.Error
+			if assert.True(t, errors.As(err, &appErr)) {
+				assert.True(t, errors.Is(err, app.ErrValidation))
+				assert.Equal(t, "validation_failed", appErr.Code)
+				assert.Equal(t, test.expected, appErr.Fields)
+			}
 		})
 	}
 }
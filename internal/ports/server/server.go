@@ -1,21 +1,100 @@
 package server
 
 import (
-	"github.com/yberikov/configLoader"
+	"context"
+	"crypto/tls"
+	"errors"
+	"homework/internal/config"
+	"homework/internal/server"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
+var ErrShuttingDown = errors.New("server is shutting down")
+
 type Server struct {
-	httpServer *http.Server
+	mu           sync.Mutex
+	httpServer   *http.Server
+	shuttingDown bool
+	ready        atomic.Bool
 }
 
-func (s *Server) Run(cfg *configLoader.Config, handler http.Handler) error {
+// Run serves handler until Shutdown is called, it returns nil after a graceful shutdown.
+// It serves HTTPS when cfg.TLS.CertFile is set. If Shutdown was called before Run,
+// Run returns http.ErrServerClosed without listening.
+func (s *Server) Run(cfg *config.Config, handler http.Handler) error {
+	var tlsConfig *tls.Config
+	if cfg.TLS.CertFile != "" {
+		var err error
+		if tlsConfig, err = server.NewTLSConfig(cfg.TLS); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
 	s.httpServer = &http.Server{
-		Addr:         cfg.Host + ":" + cfg.Port,
+		Addr:         cfg.Address,
 		Handler:      handler,
-		ReadTimeout:  cfg.Timeout,
-		WriteTimeout: cfg.Timeout,
+		ReadTimeout:  cfg.HTTPServer.Timeout,
+		WriteTimeout: cfg.HTTPServer.Timeout,
+		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
+		TLSConfig:    tlsConfig,
+	}
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	listener, err := net.Listen("tcp", httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	s.ready.Store(true)
+
+	if httpServer.TLSConfig != nil {
+		err = httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = httpServer.Serve(listener)
 	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
 
-	return s.httpServer.ListenAndServe()
+// SetReady flips the readiness reported by Check, e.g. to let load balancers
+// stop routing new requests before Shutdown.
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// Check implements health.Checker, it fails once the server is shutting down.
+func (s *Server) Check(context.Context) error {
+	if !s.Ready() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+// Shutdown marks the server not ready, stops accepting connections and waits for in-flight
+// requests to finish, a later Run doesn't start serving. It returns ctx.Err() if they don't finish before ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.ready.Store(false)
+
+	s.mu.Lock()
+	s.shuttingDown = true
+	httpServer := s.httpServer
+	s.mu.Unlock()
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
 }
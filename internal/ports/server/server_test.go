@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/config"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func startServer(t *testing.T, handler http.Handler) (*Server, string, chan error) {
+	addr := freeAddress(t)
+	cfg := &config.Config{HTTPServer: config.HTTPServer{Address: addr, Timeout: 5 * time.Second}}
+
+	srv := new(Server)
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run(cfg, handler)
+	}()
+	for i := 0; i < 100 && !srv.Ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, srv.Ready())
+	return srv, "http://" + addr, done
+}
+
+func TestServer_ShutdownDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv, url, done := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	respCode := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			respCode <- 0
+			return
+		}
+		resp.Body.Close()
+		respCode <- resp.StatusCode
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, srv.Ready())
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-respCode)
+	assert.NoError(t, <-shutdownErr)
+	assert.NoError(t, <-done)
+}
+
+func TestServer_ShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	srv, url, done := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	go http.Get(url)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "want deadline exceeded, got %v", err)
+	assert.NoError(t, <-done)
+}
+
+func TestServer_Check(t *testing.T) {
+	srv := new(Server)
+	srv.SetReady(true)
+	assert.NoError(t, srv.Check(context.Background()))
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+	assert.ErrorIs(t, srv.Check(context.Background()), ErrShuttingDown)
+}
+
+func TestServer_ShutdownBeforeRun(t *testing.T) {
+	srv := new(Server)
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	cfg := &config.Config{HTTPServer: config.HTTPServer{Address: freeAddress(t), Timeout: 5 * time.Second}}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run(cfg, http.NotFoundHandler())
+	}()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(time.Second):
+		t.Fatal("Run kept serving after Shutdown")
+	}
+	assert.False(t, srv.Ready())
+}
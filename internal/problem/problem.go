@@ -0,0 +1,83 @@
+// Package problem writes errors as RFC 7807 problem details responses,
+// so handlers and middlewares report errors in the same format.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"homework/internal/app"
+	"homework/internal/logger"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const MediaType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response body.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Code is a machine-readable error identifier, e.g. "device_not_found".
+	Code   string           `json:"code"`
+	Errors []app.FieldError `json:"errors,omitempty"`
+}
+
+// Status maps domain errors to HTTP status codes.
+func Status(err error) int {
+	switch {
+	case errors.Is(err, app.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, app.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, app.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, app.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, app.ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WriteError writes err as a problem response with the status of its kind.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	Write(w, r, Status(err), err)
+}
+
+// Write writes err as a problem response with the status code.
+// Only messages of domain errors and of client errors are shown, the rest are logged.
+func Write(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: err.Error(),
+		Code:   strings.ReplaceAll(strings.ToLower(http.StatusText(statusCode)), " ", "_"),
+	}
+	var appErr *app.Error
+	if errors.As(err, &appErr) {
+		problem.Detail = appErr.Message
+		problem.Code = appErr.Code
+		problem.Errors = appErr.Fields
+	} else if statusCode >= http.StatusInternalServerError {
+		problem.Detail = ""
+	}
+	if statusCode >= http.StatusInternalServerError {
+		logger.FromContext(r.Context()).Error("internal error", slog.Any("error", err))
+	}
+
+	data, err := json.Marshal(problem)
+	if err != nil {
+		http.Error(w, "Failed to marshal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(data); err != nil {
+		logger.FromContext(r.Context()).Error("failed to write error response", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,211 @@
+// Package rbac authorizes device operations by the roles of the authenticated principal.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/app"
+	"homework/internal/auth"
+	"homework/internal/config"
+	"homework/internal/logger"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type Role string
+
+const (
+	Viewer   Role = "viewer"
+	Operator Role = "operator"
+	Admin    Role = "admin"
+)
+
+type Operation string
+
+const (
+	OpGetDevice     Operation = "devices:get"
+	OpListDevices   Operation = "devices:list"
+	OpCreateDevice  Operation = "devices:create"
+	OpUpdateDevice  Operation = "devices:update"
+	OpDeleteDevice  Operation = "devices:delete"
+	OpManageAPIKeys Operation = "api_keys:manage"
+	// AnyOperation matches every operation in a policy.
+	AnyOperation Operation = "*"
+)
+
+var operations = []Operation{
+	OpGetDevice, OpListDevices, OpCreateDevice, OpUpdateDevice, OpDeleteDevice, OpManageAPIKeys, AnyOperation,
+}
+
+// Policy allows a role to perform operations, on devices of any model if Models is empty.
+type Policy struct {
+	Role       Role
+	Operations []Operation
+	Models     []string
+}
+
+// DefaultPolicies let viewers read devices, operators manage them and admins do everything.
+var DefaultPolicies = []Policy{
+	{Role: Viewer, Operations: []Operation{OpGetDevice, OpListDevices}},
+	{Role: Operator, Operations: []Operation{OpGetDevice, OpListDevices, OpCreateDevice, OpUpdateDevice, OpDeleteDevice}},
+	{Role: Admin, Operations: []Operation{AnyOperation}},
+}
+
+// allows tells whether the policy lets role perform op on a device of the model.
+// An empty model means the operation isn't limited to a model, e.g. listing all devices,
+// so only policies without model restrictions allow it.
+func (p Policy) allows(role Role, op Operation, model string) bool {
+	if p.Role != role {
+		return false
+	}
+	opAllowed := false
+	for _, o := range p.Operations {
+		if o == op || o == AnyOperation {
+			opAllowed = true
+			break
+		}
+	}
+	if !opAllowed {
+		return false
+	}
+	if len(p.Models) == 0 {
+		return true
+	}
+	for _, m := range p.Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer decides whether principals may perform operations.
+type Authorizer struct {
+	policies    []Policy
+	subjects    map[string][]Role
+	defaultRole Role
+}
+
+func NewAuthorizer(cfg config.RBAC) (*Authorizer, error) {
+	a := &Authorizer{
+		policies:    DefaultPolicies,
+		subjects:    make(map[string][]Role, len(cfg.Subjects)),
+		defaultRole: Role(cfg.DefaultRole),
+	}
+	if len(cfg.Policies) > 0 {
+		a.policies = make([]Policy, 0, len(cfg.Policies))
+		for i, p := range cfg.Policies {
+			if p.Role == "" {
+				return nil, fmt.Errorf("policy %d has no role", i)
+			}
+			policy := Policy{Role: Role(p.Role), Models: p.Models}
+			for _, name := range p.Operations {
+				op := Operation(name)
+				if !validOperation(op) {
+					return nil, fmt.Errorf("policy %d: unknown operation %q", i, name)
+				}
+				policy.Operations = append(policy.Operations, op)
+			}
+			a.policies = append(a.policies, policy)
+		}
+	}
+	for subject, roles := range cfg.Subjects {
+		for _, role := range roles {
+			a.subjects[subject] = append(a.subjects[subject], Role(role))
+		}
+	}
+	return a, nil
+}
+
+// Roles returns the roles of the principal: roles it claims, roles assigned to its name in the config
+// and, for API keys, roles matching their scopes. The default role is used if there are none.
+func (a *Authorizer) Roles(p auth.Principal) []Role {
+	var roles []Role
+	for _, r := range p.Roles {
+		roles = append(roles, Role(r))
+	}
+	roles = append(roles, a.subjects[p.Name]...)
+	if p.Method == "apikey" {
+		for _, scope := range p.Scopes {
+			switch scope {
+			case auth.ScopeDevicesRead:
+				roles = append(roles, Viewer)
+			case auth.ScopeDevicesWrite:
+				roles = append(roles, Operator)
+			case auth.ScopeAdmin:
+				roles = append(roles, Admin)
+			}
+		}
+	}
+	if len(roles) == 0 && a.defaultRole != "" {
+		roles = append(roles, a.defaultRole)
+	}
+	return roles
+}
+
+// Authorize returns an app.ErrForbidden error if the principal in ctx may not perform op
+// on a device of the model. Denials are logged.
+func (a *Authorizer) Authorize(ctx context.Context, op Operation, model string) error {
+	return a.authorize(ctx, op, model, func(p Policy, role Role) bool { return p.allows(role, op, model) })
+}
+
+// authorizeOperation checks that the principal may perform op on devices of at least one model,
+// so operations on unknown devices are denied before the device is looked up.
+func (a *Authorizer) authorizeOperation(ctx context.Context, op Operation) error {
+	return a.authorize(ctx, op, "", func(p Policy, role Role) bool {
+		return len(p.Models) > 0 && p.allows(role, op, p.Models[0]) || p.allows(role, op, "")
+	})
+}
+
+func (a *Authorizer) authorize(ctx context.Context, op Operation, model string, allows func(Policy, Role) bool) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		logger.FromContext(ctx).Warn("access denied to unauthenticated caller", slog.String("operation", string(op)))
+		return forbidden(op)
+	}
+	roles := a.Roles(principal)
+	for _, role := range roles {
+		for _, p := range a.policies {
+			if allows(p, role) {
+				return nil
+			}
+		}
+	}
+	logger.FromContext(ctx).Warn("access denied",
+		slog.String("principal", principal.Name),
+		slog.Any("roles", roles),
+		slog.String("operation", string(op)),
+		slog.String("model", model),
+	)
+	return forbidden(op)
+}
+
+// Middleware authorizes requests to the admin endpoints, device operations are authorized by Service.
+// Denials are written by writeError, so they are reported like the errors of the handlers.
+func (a *Authorizer) Middleware(writeError func(http.ResponseWriter, *http.Request, error)) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/v1/admin/") {
+				if err := a.Authorize(r.Context(), OpManageAPIKeys, ""); err != nil {
+					writeError(w, r, err)
+					return
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forbidden(op Operation) error {
+	return app.NewError(app.ErrForbidden, "forbidden", fmt.Sprintf("not allowed to perform %s", op))
+}
+
+func validOperation(op Operation) bool {
+	for _, o := range operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
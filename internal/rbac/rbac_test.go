@@ -0,0 +1,174 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/adapters/fakerepo"
+	"homework/internal/app"
+	"homework/internal/auth"
+	"homework/internal/config"
+	"homework/internal/device"
+	"homework/internal/problem"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func as(p auth.Principal) context.Context {
+	return auth.WithPrincipal(context.Background(), p)
+}
+
+func TestAuthorizer_Roles(t *testing.T) {
+	authorizer, err := NewAuthorizer(config.RBAC{
+		DefaultRole: "viewer",
+		Subjects:    map[string][]string{"bob": {"operator"}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		expected  []Role
+	}{
+		{name: "Default role", principal: auth.Principal{Name: "alice", Method: "basic"}, expected: []Role{Viewer}},
+		{name: "Subject", principal: auth.Principal{Name: "bob", Method: "basic"}, expected: []Role{Operator}},
+		{name: "JWT claim", principal: auth.Principal{Name: "carol", Method: "jwt", Roles: []string{"admin"}}, expected: []Role{Admin}},
+		{
+			name:      "API key scopes",
+			principal: auth.Principal{Name: "apikey:1", Method: "apikey", Scopes: []auth.Scope{auth.ScopeDevicesRead, auth.ScopeDevicesWrite}},
+			expected:  []Role{Viewer, Operator},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, authorizer.Roles(tt.principal))
+		})
+	}
+}
+
+func TestNewAuthorizer_UnknownOperation(t *testing.T) {
+	_, err := NewAuthorizer(config.RBAC{Policies: []config.Policy{{Role: "viewer", Operations: []string{"devices:reboot"}}}})
+	assert.Error(t, err)
+}
+
+func TestService(t *testing.T) {
+	authorizer, err := NewAuthorizer(config.RBAC{
+		Subjects: map[string][]string{"viewer": {"viewer"}, "operator": {"operator"}, "hp-operator": {"hp-operator"}},
+		Policies: []config.Policy{
+			{Role: "viewer", Operations: []string{"devices:get", "devices:list"}},
+			{Role: "operator", Operations: []string{"*"}},
+			{Role: "hp-operator", Operations: []string{"devices:get", "devices:list", "devices:create", "devices:update", "devices:delete"}, Models: []string{"hp"}},
+		},
+	})
+	require.NoError(t, err)
+
+	storage := fakerepo.NewDeviceStorage()
+	service := NewService(app.NewService(storage), authorizer)
+	hp := device.Device{SerialNum: "123", Model: "hp", IP: "1.1.1.1"}
+	dell := device.Device{SerialNum: "456", Model: "dell", IP: "1.1.1.2"}
+	require.NoError(t, service.CreateDevice(as(auth.Principal{Name: "operator"}), hp))
+	require.NoError(t, service.CreateDevice(as(auth.Principal{Name: "operator"}), dell))
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	tests := []struct {
+		name    string
+		subject string
+		call    func(ctx context.Context) error
+		allowed bool
+	}{
+		{name: "Viewer gets", subject: "viewer", allowed: true,
+			call: func(ctx context.Context) error { _, err := service.GetDevice(ctx, "456"); return err }},
+		{name: "Viewer lists", subject: "viewer", allowed: true,
+			call: func(ctx context.Context) error { _, err := service.ListDevices(ctx, device.ListQuery{}); return err }},
+		{name: "Viewer can't create", subject: "viewer",
+			call: func(ctx context.Context) error {
+				return service.CreateDevice(ctx, device.Device{SerialNum: "789", Model: "hp"})
+			}},
+		{name: "Viewer can't delete", subject: "viewer",
+			call: func(ctx context.Context) error { return service.DeleteDevice(ctx, "123") }},
+		{name: "Model operator updates its model", subject: "hp-operator", allowed: true,
+			call: func(ctx context.Context) error {
+				return service.UpdateDevice(ctx, device.Device{SerialNum: "123", Model: "hp", IP: "1.1.1.3"})
+			}},
+		{name: "Model operator can't move device to another model", subject: "hp-operator",
+			call: func(ctx context.Context) error {
+				return service.UpdateDevice(ctx, device.Device{SerialNum: "123", Model: "dell", IP: "1.1.1.3"})
+			}},
+		{name: "Model operator can't get other models", subject: "hp-operator",
+			call: func(ctx context.Context) error { _, err := service.GetDevice(ctx, "456"); return err }},
+		{name: "Model operator can't delete other models", subject: "hp-operator",
+			call: func(ctx context.Context) error { return service.DeleteDevice(ctx, "456") }},
+		{name: "Model operator can't update other models", subject: "hp-operator",
+			call: func(ctx context.Context) error {
+				return service.UpdateDevice(ctx, device.Device{SerialNum: "456", Model: "hp", IP: "1.1.1.3"})
+			}},
+		{name: "Model operator lists its model", subject: "hp-operator", allowed: true,
+			call: func(ctx context.Context) error {
+				_, err := service.ListDevices(ctx, device.ListQuery{Model: "hp"})
+				return err
+			}},
+		{name: "Model operator can't list all devices", subject: "hp-operator",
+			call: func(ctx context.Context) error { _, err := service.ListDevices(ctx, device.ListQuery{}); return err }},
+		{name: "Unknown subject has no roles", subject: "mallory",
+			call: func(ctx context.Context) error { _, err := service.GetDevice(ctx, "123"); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			err := tt.call(as(auth.Principal{Name: tt.subject}))
+			if tt.allowed {
+				assert.NoError(t, err)
+				assert.Empty(t, logs.String())
+				return
+			}
+			assert.True(t, errors.Is(err, app.ErrForbidden), "want forbidden, got %v", err)
+			assert.Contains(t, logs.String(), "access denied principal="+tt.subject)
+		})
+	}
+
+	_, err = service.GetDevice(as(auth.Principal{Name: "hp-operator"}), "789")
+	assert.True(t, errors.Is(err, app.ErrNotFound), "missing devices are not found, got %v", err)
+
+	_, err = service.GetDevice(context.Background(), "123")
+	assert.True(t, errors.Is(err, app.ErrForbidden), "unauthenticated callers are denied, got %v", err)
+}
+
+func TestAuthorizer_Middleware(t *testing.T) {
+	authorizer, err := NewAuthorizer(config.RBAC{Subjects: map[string][]string{"root": {"admin"}, "bob": {"operator"}}})
+	require.NoError(t, err)
+	h := authorizer.Middleware(problem.WriteError)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		subject string
+		target  string
+		status  int
+	}{
+		{subject: "root", target: "/v1/admin/api-keys", status: http.StatusOK},
+		{subject: "bob", target: "/v1/admin/api-keys", status: http.StatusForbidden},
+		{subject: "bob", target: "/v1/devices", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject+" "+tt.target, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req = req.WithContext(as(auth.Principal{Name: tt.subject}))
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status == http.StatusForbidden {
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+				var p problem.Problem
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+				assert.Equal(t, "forbidden", p.Code)
+			}
+		})
+	}
+}
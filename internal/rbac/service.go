@@ -0,0 +1,91 @@
+package rbac
+
+import (
+	"context"
+	"homework/internal/device"
+)
+
+// DeviceService is the service protected by Service, it matches handler.Service.
+type DeviceService interface {
+	GetDevice(context.Context, string) (device.Device, error)
+	CreateDevice(context.Context, device.Device) error
+	DeleteDevice(context.Context, string) error
+	UpdateDevice(context.Context, device.Device) error
+	ListDevices(context.Context, device.ListQuery) (device.Page, error)
+}
+
+// Service authorizes every call before passing it to the next service.
+// Operations on existing devices look the device up first to check the policies of its model.
+// The lookup and the operation aren't atomic: a device deleted and recreated with another model
+// in between is updated or deleted under the policies of the model it had at the lookup.
+type Service struct {
+	next       DeviceService
+	authorizer *Authorizer
+}
+
+func NewService(next DeviceService, authorizer *Authorizer) *Service {
+	return &Service{next: next, authorizer: authorizer}
+}
+
+func (s *Service) GetDevice(ctx context.Context, serialNum string) (device.Device, error) {
+	if err := s.authorizer.authorizeOperation(ctx, OpGetDevice); err != nil {
+		return device.Device{}, err
+	}
+	d, err := s.lookup(ctx, OpGetDevice, serialNum)
+	if err != nil {
+		return device.Device{}, err
+	}
+	return d, nil
+}
+
+func (s *Service) CreateDevice(ctx context.Context, d device.Device) error {
+	if err := s.authorizer.Authorize(ctx, OpCreateDevice, d.Model); err != nil {
+		return err
+	}
+	return s.next.CreateDevice(ctx, d)
+}
+
+func (s *Service) DeleteDevice(ctx context.Context, serialNum string) error {
+	if err := s.authorizer.authorizeOperation(ctx, OpDeleteDevice); err != nil {
+		return err
+	}
+	if _, err := s.lookup(ctx, OpDeleteDevice, serialNum); err != nil {
+		return err
+	}
+	return s.next.DeleteDevice(ctx, serialNum)
+}
+
+// UpdateDevice checks both the current and the new model, so a device can't be moved
+// out of or into a model the caller may not update.
+func (s *Service) UpdateDevice(ctx context.Context, d device.Device) error {
+	if err := s.authorizer.authorizeOperation(ctx, OpUpdateDevice); err != nil {
+		return err
+	}
+	if _, err := s.lookup(ctx, OpUpdateDevice, d.SerialNum); err != nil {
+		return err
+	}
+	if err := s.authorizer.Authorize(ctx, OpUpdateDevice, d.Model); err != nil {
+		return err
+	}
+	return s.next.UpdateDevice(ctx, d)
+}
+
+// ListDevices requires a model filter if the caller may list devices of some models only.
+func (s *Service) ListDevices(ctx context.Context, query device.ListQuery) (device.Page, error) {
+	if err := s.authorizer.Authorize(ctx, OpListDevices, query.Model); err != nil {
+		return device.Page{}, err
+	}
+	return s.next.ListDevices(ctx, query)
+}
+
+// lookup returns the device if the caller may perform op on its model.
+func (s *Service) lookup(ctx context.Context, op Operation, serialNum string) (device.Device, error) {
+	d, err := s.next.GetDevice(ctx, serialNum)
+	if err != nil {
+		return device.Device{}, err
+	}
+	if err := s.authorizer.Authorize(ctx, op, d.Model); err != nil {
+		return device.Device{}, err
+	}
+	return d, nil
+}
@@ -0,0 +1,134 @@
+// Package redact hides secrets in headers, query parameters and JSON bodies before they are logged.
+package redact
+
+import (
+	"encoding/json"
+	"homework/internal/config"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Placeholder replaces redacted values.
+const Placeholder = "[REDACTED]"
+
+// Default names of values that are always redacted.
+var (
+	DefaultHeaders     = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key"}
+	DefaultQueryParams = []string{"access_token", "api_key", "password", "token"}
+	DefaultBodyFields  = []string{"password", "secret", "token", "key"}
+)
+
+// Policy lists headers, query parameters and JSON body fields whose values are redacted.
+// Names are matched case-insensitively, the zero Policy redacts nothing.
+type Policy struct {
+	headers     map[string]struct{}
+	queryParams map[string]struct{}
+	bodyFields  map[string]struct{}
+}
+
+// NewPolicy redacts the given names.
+func NewPolicy(headers, queryParams, bodyFields []string) *Policy {
+	return &Policy{
+		headers:     nameSet(headers),
+		queryParams: nameSet(queryParams),
+		bodyFields:  nameSet(bodyFields),
+	}
+}
+
+// DefaultPolicy redacts the default names.
+func DefaultPolicy() *Policy {
+	return NewPolicy(DefaultHeaders, DefaultQueryParams, DefaultBodyFields)
+}
+
+// PolicyFromConfig redacts the default names and the ones from the config.
+func PolicyFromConfig(cfg config.Redaction) *Policy {
+	return NewPolicy(
+		append(append([]string(nil), DefaultHeaders...), cfg.Headers...),
+		append(append([]string(nil), DefaultQueryParams...), cfg.QueryParams...),
+		append(append([]string(nil), DefaultBodyFields...), cfg.BodyFields...),
+	)
+}
+
+func nameSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = struct{}{}
+	}
+	return set
+}
+
+func has(set map[string]struct{}, name string) bool {
+	_, ok := set[strings.ToLower(name)]
+	return ok
+}
+
+// Header returns a copy of h with redacted values.
+func (p *Policy) Header(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		if has(p.headers, name) {
+			redacted[name] = []string{Placeholder}
+			continue
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+// Query returns a copy of values with redacted query parameters.
+func (p *Policy) Query(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for name, v := range values {
+		if has(p.queryParams, name) {
+			redacted[name] = []string{Placeholder}
+			continue
+		}
+		redacted[name] = v
+	}
+	return redacted
+}
+
+// URL returns u as a string with redacted query parameters and password.
+func (p *Policy) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	if c.RawQuery != "" {
+		c.RawQuery = p.Query(c.Query()).Encode()
+	}
+	return c.Redacted()
+}
+
+// JSON returns body with redacted fields at any depth. Bodies that aren't valid JSON
+// are returned as is.
+func (p *Policy) JSON(body []byte) []byte {
+	var v any
+	if len(p.bodyFields) == 0 || json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	redacted, err := json.Marshal(p.redactValue(v))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func (p *Policy) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for name, field := range v {
+			if has(p.bodyFields, name) {
+				v[name] = Placeholder
+				continue
+			}
+			v[name] = p.redactValue(field)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = p.redactValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,54 @@
+// Package reqinfo records the route and the response status of a request once for all
+// the middlewares that log, measure and trace it.
+package reqinfo
+
+import (
+	"context"
+	"net/http"
+)
+
+// Info is filled while the request is served, read it after the handler returns.
+type Info struct {
+	route  string
+	status int
+}
+
+// Route returns the route pattern set by the handler, e.g. "/v1/devices/{id}",
+// or an empty string if no handler matched the request.
+func (i *Info) Route() string {
+	return i.route
+}
+
+// Status returns the response status, http.StatusOK if the handler didn't set one.
+func (i *Info) Status() int {
+	return i.status
+}
+
+type key struct{}
+
+// Record returns w and r set up to fill the Info of the request.
+// Middlewares nested in one that already records the request share its Info.
+func Record(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, *Info) {
+	if info, ok := r.Context().Value(key{}).(*Info); ok {
+		return w, r, info
+	}
+	info := &Info{status: http.StatusOK}
+	return &recorder{ResponseWriter: w, info: info}, r.WithContext(context.WithValue(r.Context(), key{}, info)), info
+}
+
+// SetRoute records the route pattern of the request, it does nothing outside of Record.
+func SetRoute(ctx context.Context, route string) {
+	if info, ok := ctx.Value(key{}).(*Info); ok {
+		info.route = route
+	}
+}
+
+type recorder struct {
+	http.ResponseWriter
+	info *Info
+}
+
+func (w *recorder) WriteHeader(code int) {
+	w.info.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,49 @@
+package reqinfo
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		route  string
+		status int
+	}{
+		{name: "Defaults"},
+		{name: "Route and status", route: "/v1/devices/{id}", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, r, outer := Record(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.route != "" {
+					SetRoute(r.Context(), tt.route)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+			})
+			// a nested middleware shares the Info of the outer one
+			nestedW, nestedR, nested := Record(w, r)
+			inner.ServeHTTP(nestedW, nestedR)
+
+			assert.Same(t, outer, nested)
+			assert.Equal(t, tt.route, outer.Route())
+			want := tt.status
+			if want == 0 {
+				want = http.StatusOK
+			}
+			assert.Equal(t, want, outer.Status())
+		})
+	}
+}
+
+func TestSetRoute_WithoutRecord(t *testing.T) {
+	assert.NotPanics(t, func() {
+		SetRoute(httptest.NewRequest("GET", "/", nil).Context(), "/v1/devices")
+	})
+}
@@ -0,0 +1,50 @@
+// Package requestid carries the ID correlating a request with the log lines and outbound calls it causes.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header carries request IDs in requests and responses.
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs accepted from callers, so they can't flood the logs.
+const maxLength = 128
+
+// New returns a random ID.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid tells whether an ID from a caller may be used as is:
+// it is not longer than 128 characters of printable ASCII without spaces.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+type key struct{}
+
+// WithContext returns a copy of ctx carrying the ID.
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// FromContext returns the ID stored by WithContext.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(key{}).(string)
+	return id, ok
+}
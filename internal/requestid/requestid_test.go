@@ -0,0 +1,38 @@
+package requestid
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{id: "0c61380f39e0673a192be80619dcb7f7", valid: true},
+		{id: "trace:update/42", valid: true},
+		{id: ""},
+		{id: "with space"},
+		{id: "new\nline"},
+		{id: "ünicode"},
+		{id: strings.Repeat("a", 128), valid: true},
+		{id: strings.Repeat("a", 129)},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.valid, Valid(tt.id), "%q", tt.id)
+	}
+}
+
+func TestNew(t *testing.T) {
+	id := New()
+	assert.True(t, Valid(id))
+	assert.NotEqual(t, id, New())
+
+	ctx := WithContext(context.Background(), id)
+	fromContext, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, id, fromContext)
+}
@@ -0,0 +1,189 @@
+package roundtripper
+
+import (
+	"errors"
+	"fmt"
+	"homework/internal/config"
+	"homework/internal/logger"
+	"homework/internal/metrics"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// breakerDefaults replace zero fields of config.Breaker.
+var breakerDefaults = config.Breaker{
+	ConsecutiveFailures: 4,
+	MinRequests:         10,
+	OpenTimeout:         5 * time.Second,
+	HalfOpenMaxRequests: 1,
+	FailureStatuses:     []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	IdleTimeout:         10 * time.Minute,
+}
+
+// BreakerRoundTripper fails fast while the breaker of the destination host is open.
+// Connection errors and responses with a failure status count as failures,
+// such responses are still returned, so outer round trippers can read them.
+type BreakerRoundTripper struct {
+	Breakers *HostBreakers
+	Next     http.RoundTripper
+}
+
+func (l BreakerRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	cb := l.Breakers.Get(r.URL.Host)
+	resp, err := cb.Execute(func() (any, error) {
+		resp, err := l.Next.RoundTrip(r)
+		if err != nil {
+			logger.FromContext(r.Context()).Warn("error making request", slog.Any("error", err))
+		} else if l.Breakers.isFailure(resp.StatusCode) {
+			err = failureStatusError{resp: resp}
+		}
+		return resp, err
+	})
+
+	var failure failureStatusError
+	if errors.As(err, &failure) {
+		return failure.resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if httpResponse, ok := resp.(*http.Response); ok {
+		return httpResponse, nil
+	}
+	return nil, fmt.Errorf("Unexpected response type")
+}
+
+// failureStatusError makes the breaker count a response with a failure status.
+type failureStatusError struct {
+	resp *http.Response
+}
+
+func (e failureStatusError) Error() string {
+	return "Server error: " + e.resp.Status
+}
+
+// HostBreakers keeps a circuit breaker per destination host, so a failing dependency
+// doesn't block requests to the others. Breakers are created on first use and dropped
+// once closed and idle for cfg.IdleTimeout, so hosts no longer called don't pile up.
+type HostBreakers struct {
+	cfg             config.Breaker
+	failureStatuses map[int]struct{}
+
+	mu        sync.Mutex
+	byHost    map[string]*hostBreaker
+	lastSweep time.Time
+}
+
+type hostBreaker struct {
+	cb       *gobreaker.CircuitBreaker
+	lastUsed time.Time
+}
+
+func NewHostBreakers(cfg config.Breaker) *HostBreakers {
+	cfg = withBreakerDefaults(cfg)
+	statuses := make(map[int]struct{}, len(cfg.FailureStatuses))
+	for _, status := range cfg.FailureStatuses {
+		statuses[status] = struct{}{}
+	}
+	return &HostBreakers{
+		cfg:             cfg,
+		failureStatuses: statuses,
+		byHost:          make(map[string]*hostBreaker),
+		lastSweep:       time.Now(),
+	}
+}
+
+// Get returns the breaker of host, it is named after the host.
+func (b *HostBreakers) Get(host string) *gobreaker.CircuitBreaker {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.lastSweep) >= b.cfg.IdleTimeout {
+		b.evictIdle(now)
+	}
+	hb, ok := b.byHost[host]
+	if !ok {
+		hb = &hostBreaker{cb: NewBreaker(host, b.cfg)}
+		b.byHost[host] = hb
+	}
+	hb.lastUsed = now
+	return hb.cb
+}
+
+// Breakers returns the breakers of the hosts called recently.
+func (b *HostBreakers) Breakers() []*gobreaker.CircuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breakers := make([]*gobreaker.CircuitBreaker, 0, len(b.byHost))
+	for _, hb := range b.byHost {
+		breakers = append(breakers, hb.cb)
+	}
+	return breakers
+}
+
+// evictIdle drops closed breakers idle for cfg.IdleTimeout, open and half-open ones are kept
+// to go on failing fast. b.mu must be held.
+func (b *HostBreakers) evictIdle(now time.Time) {
+	b.lastSweep = now
+	for host, hb := range b.byHost {
+		if now.Sub(hb.lastUsed) >= b.cfg.IdleTimeout && hb.cb.State() == gobreaker.StateClosed {
+			delete(b.byHost, host)
+			metrics.BreakerRemoved(host)
+		}
+	}
+}
+
+func (b *HostBreakers) isFailure(status int) bool {
+	_, ok := b.failureStatuses[status]
+	return ok
+}
+
+// NewBreaker returns a breaker configured by cfg, zero fields keep the defaults: it trips
+// after 4 consecutive failures, stays open for 5s and lets one trial request through when half-open.
+// Its state is exported as a metric.
+func NewBreaker(name string, cfg config.Breaker) *gobreaker.CircuitBreaker {
+	cfg = withBreakerDefaults(cfg)
+	metrics.BreakerCreated(name)
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:          name,
+		MaxRequests:   cfg.HalfOpenMaxRequests,
+		Interval:      cfg.Interval,
+		Timeout:       cfg.OpenTimeout,
+		OnStateChange: metrics.BreakerStateChanged,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			if counts.ConsecutiveFailures >= cfg.ConsecutiveFailures {
+				return true
+			}
+			return cfg.FailureRatio > 0 && counts.Requests >= cfg.MinRequests &&
+				float64(counts.TotalFailures) >= cfg.FailureRatio*float64(counts.Requests)
+		},
+	})
+	return cb
+}
+
+func withBreakerDefaults(cfg config.Breaker) config.Breaker {
+	if cfg.ConsecutiveFailures == 0 {
+		cfg.ConsecutiveFailures = breakerDefaults.ConsecutiveFailures
+	}
+	if cfg.MinRequests == 0 {
+		cfg.MinRequests = breakerDefaults.MinRequests
+	}
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = breakerDefaults.OpenTimeout
+	}
+	if cfg.HalfOpenMaxRequests == 0 {
+		cfg.HalfOpenMaxRequests = breakerDefaults.HalfOpenMaxRequests
+	}
+	if len(cfg.FailureStatuses) == 0 {
+		cfg.FailureStatuses = breakerDefaults.FailureStatuses
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = breakerDefaults.IdleTimeout
+	}
+	return cfg
+}
@@ -0,0 +1,190 @@
+package roundtripper
+
+import (
+	"context"
+	"errors"
+	"homework/internal/logger"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// IdempotencyKeyHeader marks non-idempotent requests that are safe to retry because the server
+// deduplicates them by the key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryRoundTripper retries requests that failed with a connection error, 429 or a 5xx status,
+// other than 501, with exponential backoff and full jitter. A Retry-After header of the response
+// replaces the backoff delay. Only idempotent requests, or ones with an Idempotency-Key header,
+// whose body can be rewound with GetBody are retried.
+type RetryRoundTripper struct {
+	Next http.RoundTripper
+	// MaxAttempts includes the first attempt, values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the backoff delay before the first retry, it doubles with every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay, responses asking to retry after a longer delay are returned as is.
+	MaxDelay time.Duration
+	// Budget limits retries across all requests of the client, nil means no limit.
+	Budget *RetryBudget
+}
+
+func (rt RetryRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if rt.Budget != nil {
+		rt.Budget.deposit()
+	}
+	if rt.MaxAttempts < 2 || !retryable(r) {
+		return rt.Next.RoundTrip(r)
+	}
+
+	lg := logger.FromContext(r.Context())
+	for attempt := 1; ; attempt++ {
+		req := r
+		if attempt > 1 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			// round trippers must not modify the request
+			req = r.Clone(r.Context())
+			req.Body = body
+		}
+
+		resp, err := rt.Next.RoundTrip(req)
+		if attempt == rt.MaxAttempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		delay, ok := rt.delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if rt.Budget != nil && !rt.Budget.withdraw() {
+			lg.Warn("retry budget exhausted", slog.String("method", r.Method), slog.String("host", r.URL.Host))
+			return resp, err
+		}
+
+		args := []any{slog.Int("attempt", attempt), slog.Duration("delay", delay), slog.String("method", r.Method), slog.String("host", r.URL.Host)}
+		if err != nil {
+			args = append(args, slog.Any("error", err))
+		} else {
+			args = append(args, slog.Int("status", resp.StatusCode))
+			drain(resp.Body)
+		}
+		lg.Debug("retrying request", args...)
+
+		if err := sleep(r.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether r may be sent again.
+func retryable(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		// the breaker rejects requests without sending them while it is open
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, gobreaker.ErrOpenState) && !errors.Is(err, gobreaker.ErrTooManyRequests)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented
+}
+
+// delay returns how long to wait before the next attempt, it is false if the server asks
+// to retry later than MaxDelay.
+func (rt RetryRoundTripper) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return after, rt.MaxDelay <= 0 || after <= rt.MaxDelay
+		}
+	}
+	backoff := rt.BaseDelay << (attempt - 1)
+	if rt.MaxDelay > 0 && (backoff > rt.MaxDelay || backoff <= 0) {
+		backoff = rt.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1)), true
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// drain reads a bit of the discarded response body, so the connection can be reused, and closes it.
+func drain(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(body, 4<<10))
+	body.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryBudget caps retries to a fraction of requests: every request deposits ratio tokens,
+// every retry withdraws a whole one. The budget starts full and holds at most maxTokens,
+// which bounds retry bursts after quiet periods.
+type RetryBudget struct {
+	ratio     float64
+	maxTokens float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+func NewRetryBudget(ratio float64, maxTokens int) *RetryBudget {
+	return &RetryBudget{ratio: ratio, maxTokens: float64(maxTokens), tokens: float64(maxTokens)}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.maxTokens)
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -1,74 +1,122 @@
 package roundtripper
 
 import (
-	"fmt"
-	"github.com/sony/gobreaker"
-	"log"
+	"homework/internal/logger"
+	"homework/internal/redact"
+	"homework/internal/requestid"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type LoggingRoundTripper struct {
 	Next http.RoundTripper
+	// Redaction hides secrets in the logged URL and headers, redact.DefaultPolicy is used if it is nil.
+	Redaction *redact.Policy
+	// Logger is used instead of the logger of the request context if it is set.
+	Logger *slog.Logger
 }
 
 func (l LoggingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	log.Printf("Outgoing Request: %s %s %v", r.Method, r.URL, r.Header)
+	policy := l.Redaction
+	if policy == nil {
+		policy = redact.DefaultPolicy()
+	}
+	lg := l.Logger
+	if lg == nil {
+		lg = logger.FromContext(r.Context())
+	}
+	lg = lg.With(slog.String("method", r.Method), slog.String("url", policy.URL(r.URL)))
+	lg.Debug("outgoing request", slog.Any("headers", policy.Header(r.Header)))
 
+	start := time.Now()
 	resp, err := l.Next.RoundTrip(r)
+	latency := slog.Duration("latency", time.Since(start))
 
 	if resp != nil {
-		log.Printf("Incoming Response: %d %v", resp.StatusCode, resp.Header)
+		lg.Info("incoming response", slog.Int("status", resp.StatusCode), latency)
+		lg.Debug("response headers", slog.Any("headers", policy.Header(resp.Header)))
 	} else if err != nil {
-		log.Printf("Error in Response: %v", err)
+		lg.Warn("round trip failed", slog.Any("error", err), latency)
 	}
 
 	return resp, err
 }
 
-type BreakerRoundTripper struct {
-	Cb   *gobreaker.CircuitBreaker
+// RequestIDRoundTripper forwards the request ID from the request context in the X-Request-ID header,
+// so downstream services log the ID of the request that caused the call.
+type RequestIDRoundTripper struct {
 	Next http.RoundTripper
 }
 
-func (l BreakerRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+func (rt RequestIDRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	id, ok := requestid.FromContext(r.Context())
+	if !ok || r.Header.Get(requestid.Header) != "" {
+		return rt.Next.RoundTrip(r)
+	}
+	// round trippers must not modify the request
+	r = r.Clone(r.Context())
+	r.Header.Set(requestid.Header, id)
+	return rt.Next.RoundTrip(r)
+}
 
-	resp, err := l.Cb.Execute(func() (any, error) {
-		resp, err := l.Next.RoundTrip(r)
-		if err != nil {
-			log.Printf("Error making request: %v", err)
-		} else if resp.StatusCode == 500 {
-			err = fmt.Errorf("Server error: %s", resp.Status)
-		}
-		return resp, err
-	})
+// TracingRoundTripper records outgoing requests in client spans and propagates the trace context
+// of the request to the server in the W3C traceparent header.
+type TracingRoundTripper struct {
+	Next http.RoundTripper
+	// Redaction hides secrets in the recorded URL, redact.DefaultPolicy is used if it is nil.
+	Redaction *redact.Policy
+}
 
-	if err != nil {
-		return nil, err
+func (rt TracingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	policy := rt.Redaction
+	if policy == nil {
+		policy = redact.DefaultPolicy()
 	}
+	ctx, span := otel.Tracer("homework/internal/roundtripper").Start(r.Context(), "HTTP "+r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPMethod(r.Method), semconv.URLFull(policy.URL(r.URL))),
+	)
+	defer span.End()
+
+	// round trippers must not modify the request
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
 
-	if httpResponse, ok := resp.(*http.Response); ok {
-		return httpResponse, nil
+	resp, err := rt.Next.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
 	}
-	return nil, fmt.Errorf("Unexpected response type")
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
 }
 
-func CreateCb() *gobreaker.CircuitBreaker {
-	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
-		Name:    "HTTP GET",
-		Timeout: 5 * time.Second,
-		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			return counts.ConsecutiveFailures > 3
-		},
-	})
-	return cb
-}
+// Func adapts a function to http.RoundTripper.
+type Func func(*http.Request) (*http.Response, error)
 
-type UserRoundTripper struct {
-	Transport     http.RoundTripper
-	UserRoundTrip http.RoundTripper
+func (f Func) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
 }
 
-func (urt UserRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	return urt.UserRoundTrip.RoundTrip(req)
+// Middleware wraps the next round tripper, e.g. to modify requests or inspect responses,
+// and delegates to it.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain wraps next into middlewares, the first one being the innermost.
+func Chain(next http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	for _, mw := range middlewares {
+		next = mw(next)
+	}
+	return next
 }
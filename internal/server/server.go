@@ -1,35 +1,89 @@
 package server
 
 import (
+	"homework/internal/auth"
 	"homework/internal/config"
 	"homework/internal/handler"
+	"homework/internal/metrics"
 	"homework/internal/middleware"
+	"homework/internal/redact"
+	"homework/internal/tracing"
+	"log/slog"
 	"net/http"
 )
 
-func NewServer(cfg *config.Config, customMiddlewares ...func(http.Handler) http.Handler) *http.Server {
+// NewServer returns a server for cfg, it serves HTTPS when cfg.TLS.CertFile is set
+// and is started with ListenAndServeTLS("", "") then.
+func NewServer(cfg *config.Config, customMiddlewares ...func(http.Handler) http.Handler) (*http.Server, error) {
 	mux := http.NewServeMux()
 
 	deviceHandler := http.HandlerFunc(handler.GetDevice)
 
 	mux.Handle("/device", deviceHandler)
 
-	var muxWithMiddleware http.Handler
-	muxWithMiddleware = mux
-
-	for _, mw := range customMiddlewares {
-		muxWithMiddleware = mw(muxWithMiddleware)
+	admins := auth.NewAdmins(cfg.Admins)
+	authn := Authentication{Basic: auth.NewStaticAuthenticator(cfg.User, cfg.Password, admins), Admins: admins}
+	if cfg.TLS.ClientCAFile != "" {
+		authn.ClientCert = true
 	}
 
-	muxWithMiddleware = middleware.BasicAuthMiddleware(muxWithMiddleware)
-	muxWithMiddleware = middleware.LoggingMiddleware(muxWithMiddleware)
-
 	srv := &http.Server{
 		Addr:         cfg.Address,
-		Handler:      muxWithMiddleware,
+		Handler:      Chain(mux, authn, redact.PolicyFromConfig(cfg.Redaction), customMiddlewares...),
 		ReadTimeout:  cfg.HTTPServer.Timeout,
 		WriteTimeout: cfg.HTTPServer.Timeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
-	return srv
+	if cfg.TLS.CertFile != "" {
+		tlsConfig, err := NewTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+	return srv, nil
+}
+
+// Authentication configures how API callers authenticate.
+type Authentication struct {
+	// Basic checks basic auth credentials, it is required.
+	Basic auth.Authenticator
+	// APIKeys enables "Authorization: Bearer" and X-API-Key authentication when set.
+	APIKeys auth.KeyAuthenticator
+	// JWT enables authentication with JWTs as "Authorization: Bearer" tokens when set.
+	JWT auth.TokenVerifier
+	// ClientCert authenticates callers presenting a verified TLS client certificate,
+	// explicit API key and JWT credentials take precedence over it.
+	ClientCert bool
+	// Admins are the client certificate names granted every scope.
+	Admins auth.Admins
+}
+
+// Chain wraps h into custom middlewares, the first one being the innermost,
+// followed by scope checks, authentication, request logging with slog.Default redacted
+// by the policy, tracing, request metrics and request ID handling. redact.DefaultPolicy is used if the policy is nil.
+func Chain(h http.Handler, authn Authentication, redaction *redact.Policy, customMiddlewares ...func(http.Handler) http.Handler) http.Handler {
+	for _, mw := range customMiddlewares {
+		h = mw(h)
+	}
+
+	h = middleware.ScopeMiddleware(h)
+	h = middleware.BasicAuthMiddleware(authn.Basic)(h)
+	if authn.ClientCert {
+		h = middleware.ClientCertMiddleware(authn.Admins)(h)
+	}
+	if authn.APIKeys != nil {
+		h = middleware.APIKeyMiddleware(authn.APIKeys)(h)
+	}
+	if authn.JWT != nil {
+		h = middleware.JWTMiddleware(authn.JWT)(h)
+	}
+	if redaction == nil {
+		redaction = redact.DefaultPolicy()
+	}
+	h = middleware.NewLoggingMiddleware(slog.Default(), redaction)(h)
+	h = tracing.Middleware(h)
+	h = metrics.Middleware(h)
+	h = middleware.RequestIDMiddleware(h)
+	return h
 }
@@ -3,15 +3,24 @@ package server_test
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"homework/internal/auth"
 	"homework/internal/config"
+	"homework/internal/logger"
 	"homework/internal/middleware"
+	"homework/internal/problem"
+	"homework/internal/redact"
+	"homework/internal/reqinfo"
+	"homework/internal/requestid"
 	"homework/internal/server"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -20,7 +29,7 @@ func TestLoggingMiddleware(t *testing.T) {
 	var buf bytes.Buffer
 	log.SetOutput(&buf)
 	defer func() {
-		log.SetOutput(nil)
+		log.SetOutput(os.Stderr)
 	}()
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -37,13 +46,111 @@ func TestLoggingMiddleware(t *testing.T) {
 	middleware.LoggingMiddleware(handler).ServeHTTP(recorder, req)
 
 	logOutput := buf.String()
-	expectedlogOutput := "Incoming Request: method GET, endpoint: /test"
+	expectedlogOutput := "INFO request completed method=GET path=/test status=200 latency="
 	assert.Contains(t, logOutput, expectedlogOutput)
 	assert.Equal(t, recorder.Code, http.StatusOK)
 }
 
+func TestLoggingMiddleware_Fields(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqinfo.SetRoute(r.Context(), "/v1/devices/{id}")
+		logger.AddAttrs(r.Context(), slog.String("serialNum", "1234"))
+		logger.FromContext(r.Context()).Info("device found")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	req := httptest.NewRequest("GET", "/v1/devices/1234", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rr := httptest.NewRecorder()
+	middleware.RequestIDMiddleware(middleware.NewLoggingMiddleware(l, redact.DefaultPolicy())(handler)).ServeHTTP(rr, req)
+	assert.Equal(t, "req-1", rr.Header().Get("X-Request-ID"))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+	var handlerLine, requestLine map[string]any
+	assert.NoError(t, json.Unmarshal(lines[0], &handlerLine))
+	assert.NoError(t, json.Unmarshal(lines[1], &requestLine))
+
+	assert.Equal(t, "req-1", handlerLine["request_id"])
+	assert.Equal(t, "GET", handlerLine["method"])
+	assert.Equal(t, "WARN", requestLine["level"])
+	assert.Equal(t, "request completed", requestLine["msg"])
+	assert.Equal(t, "req-1", requestLine["request_id"])
+	assert.Equal(t, "/v1/devices/1234", requestLine["path"])
+	assert.Equal(t, "/v1/devices/{id}", requestLine["route"])
+	assert.Equal(t, "1234", requestLine["serialNum"])
+	assert.Equal(t, float64(http.StatusNotFound), requestLine["status"])
+	assert.Contains(t, requestLine, "latency")
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		generate bool
+	}{
+		{"Accepted", "3f2a-update-42", false},
+		{"Missing", "", true},
+		{"Too long", strings.Repeat("a", 129), true},
+		{"Control characters", "id\nforged log line", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fromContext string
+			handler := middleware.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext, _ = requestid.FromContext(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/v1/devices", nil)
+			if tc.header != "" {
+				req.Header.Set("X-Request-ID", tc.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			id := rr.Header().Get("X-Request-ID")
+			assert.Equal(t, id, fromContext)
+			if tc.generate {
+				assert.Len(t, id, 32)
+			} else {
+				assert.Equal(t, tc.header, id)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_Redaction(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"detail":"invalid","password":"hunter2"}`))
+	})
+	policy := redact.PolicyFromConfig(config.Redaction{Headers: []string{"X-Session"}})
+
+	req := httptest.NewRequest("GET", "/v1/devices?api_key=dk_1_secret&model=hp", nil)
+	req.SetBasicAuth("user", "password")
+	req.Header.Set("X-Session", "abc123")
+	middleware.NewLoggingMiddleware(l, policy)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	logOutput := buf.String()
+	for _, secret := range []string{"dk_1_secret", base64.StdEncoding.EncodeToString([]byte("user:password")), "abc123", "s3cr3t", "hunter2"} {
+		assert.NotContains(t, logOutput, secret)
+	}
+	assert.Contains(t, logOutput, "model=hp")
+	assert.Contains(t, logOutput, redact.Placeholder)
+}
+
 func TestBasicAuthMiddleware(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "user", principal.Name)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Authorized!"))
 	})
@@ -54,16 +161,17 @@ func TestBasicAuthMiddleware(t *testing.T) {
 		expectedCode int
 	}{
 		{"user", "password", http.StatusOK},
+		{"user", "invalid_password", http.StatusUnauthorized},
 		{"invalid_user", "invalid_password", http.StatusUnauthorized},
 	}
 
 	for _, tc := range testCases {
 		req, _ := http.NewRequest("GET", "/test", nil)
-		auth := base64.StdEncoding.EncodeToString([]byte(tc.username + ":" + tc.password))
-		req.Header.Set("Authorization", "Basic "+auth)
+		credentials := base64.StdEncoding.EncodeToString([]byte(tc.username + ":" + tc.password))
+		req.Header.Set("Authorization", "Basic "+credentials)
 
 		recorder := httptest.NewRecorder()
-		middleware.BasicAuthMiddleware(handler).ServeHTTP(recorder, req)
+		middleware.BasicAuthMiddleware(auth.NewStaticAuthenticator("user", "password", nil))(handler).ServeHTTP(recorder, req)
 
 		assert.Equal(t, recorder.Code, tc.expectedCode)
 
@@ -80,7 +188,10 @@ func TestCustomMiddlewareAddedToServer(t *testing.T) {
 
 	cfg := config.LoadConfig(configPath)
 
-	srv := server.NewServer(cfg, customMiddleware)
+	srv, err := server.NewServer(cfg, customMiddleware)
+	if err != nil {
+		t.Fatalf("Error creating server: %v", err)
+	}
 
 	go func() {
 		err := srv.ListenAndServe()
@@ -97,8 +208,103 @@ func TestCustomMiddlewareAddedToServer(t *testing.T) {
 
 func customMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		now := time.Now()
 		h.ServeHTTP(w, r)
-		log.Println(time.Since(now))
 	})
 }
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	keys, err := auth.NewAPIKeys(filepath.Join(t.TempDir(), "api_keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, readKey, err := keys.Create("collector", []auth.Scope{auth.ScopeDevicesRead}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, adminKey, err := keys.Create("ops", []auth.Scope{auth.ScopeAdmin}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := server.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), server.Authentication{Basic: auth.NewStaticAuthenticator("user", "password", nil), APIKeys: keys}, nil)
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:password"))
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		header       string
+		value        string
+		expectedCode int
+	}{
+		{"Bearer read", "GET", "/v1/devices", "Authorization", "Bearer " + readKey, http.StatusOK},
+		{"X-API-Key read", "GET", "/v1/devices", "X-API-Key", readKey, http.StatusOK},
+		{"Write without scope", "POST", "/v1/devices", "X-API-Key", readKey, http.StatusForbidden},
+		{"Admin without scope", "GET", "/v1/admin/api-keys", "X-API-Key", readKey, http.StatusForbidden},
+		{"Admin", "DELETE", "/v1/devices/1234", "X-API-Key", adminKey, http.StatusOK},
+		{"Basic write", "POST", "/v1/devices", "Authorization", basic, http.StatusOK},
+		{"Basic admin without config", "GET", "/v1/admin/api-keys", "Authorization", basic, http.StatusForbidden},
+		{"Invalid key", "GET", "/v1/devices", "Authorization", "Bearer dk_nope_nope", http.StatusUnauthorized},
+		{"No credentials", "GET", "/v1/devices", "", "", http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+			if tc.expectedCode == http.StatusForbidden {
+				assert.Equal(t, problem.MediaType, recorder.Header().Get("Content-Type"))
+				assert.Contains(t, recorder.Body.String(), `"code":"insufficient_scope"`)
+			}
+		})
+	}
+}
+
+type stubVerifier struct{}
+
+func (stubVerifier) VerifyToken(token string) (auth.Principal, error) {
+	if token == "header.noscope.signature" {
+		return auth.Principal{Name: "bob", Method: "jwt", Claims: map[string]any{"sub": "bob"}}, nil
+	}
+	if token != "header.claims.signature" {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+	return auth.Principal{Name: "alice", Method: "jwt", Scopes: []auth.Scope{auth.ScopeDevicesRead},
+		Claims: map[string]any{"sub": "alice"}}, nil
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	var principal auth.Principal
+	handler := server.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = auth.PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}), server.Authentication{Basic: auth.NewStaticAuthenticator("user", "password", nil), JWT: stubVerifier{}}, nil)
+
+	testCases := []struct {
+		name         string
+		method       string
+		token        string
+		expectedCode int
+	}{
+		{"Valid token", "GET", "header.claims.signature", http.StatusOK},
+		{"Missing scope", "POST", "header.claims.signature", http.StatusForbidden},
+		{"Token without scopes", "GET", "header.noscope.signature", http.StatusForbidden},
+		{"Invalid token", "GET", "header.claims.forged", http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "/v1/devices", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+	assert.Equal(t, "alice", principal.Claims["sub"])
+}
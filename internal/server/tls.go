@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"homework/internal/config"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTLSCertRequired     = errors.New("TLS requires a certificate and a key file")
+	ErrTLSClientCARequired = errors.New("requiring client certificates needs a client CA file")
+)
+
+// NewTLSConfig returns the server TLS config. The certificate, key and client CA files are
+// checked for changes at most once per cfg.ReloadInterval during handshakes and reloaded
+// when they change, a zero interval disables reloading.
+func NewTLSConfig(cfg config.TLS) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, ErrTLSCertRequired
+	}
+	if cfg.RequireClientCert && cfg.ClientCAFile == "" {
+		return nil, ErrTLSClientCARequired
+	}
+	minVersion, err := tlsVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certReloader{cfg: cfg}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion: minVersion,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+	}
+	if cfg.ClientCAFile == "" {
+		return base, nil
+	}
+	base.ClientAuth = tls.VerifyClientCertIfGiven
+	if cfg.RequireClientCert {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		_, clientCAs := r.current()
+		c := base.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = clientCAs
+		return c, nil
+	}
+	return base, nil
+}
+
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported minimum TLS version %q", version)
+	}
+}
+
+// certReloader keeps the certificate and client CAs loaded from the files in cfg.
+type certReloader struct {
+	cfg config.TLS
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	files     map[string]fileVersion
+	checkedAt time.Time
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// current returns the loaded certificate and client CAs, reloading them first if they are due
+// for a check and changed. On reload errors the previous ones are kept.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	due := r.cfg.ReloadInterval > 0 && time.Since(r.checkedAt) > r.cfg.ReloadInterval
+	if due {
+		r.checkedAt = time.Now()
+	}
+	changed := due && r.changedLocked()
+	r.mu.Unlock()
+
+	if changed {
+		if err := r.reload(); err != nil {
+			slog.Error("failed to reload TLS certificates, keeping previous ones", slog.Any("error", err))
+		} else {
+			slog.Info("reloaded TLS certificate", slog.String("path", r.cfg.CertFile))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, r.clientCAs
+}
+
+func (r *certReloader) paths() []string {
+	paths := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		paths = append(paths, r.cfg.ClientCAFile)
+	}
+	return paths
+}
+
+func (r *certReloader) changedLocked() bool {
+	for _, path := range r.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if v := r.files[path]; !info.ModTime().Equal(v.modTime) || info.Size() != v.size {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) reload() error {
+	files := make(map[string]fileVersion)
+	for _, path := range r.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		files[path] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA file %s has no certificates", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.files = files
+	return nil
+}
@@ -0,0 +1,255 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homework/internal/auth"
+	"homework/internal/config"
+	"homework/internal/server"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// issue returns a certificate signed by the CA as a PEM certificate and key.
+func (ca testCA) issue(t *testing.T, serial int64, template x509.Certificate) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func serverCert() x509.Certificate {
+	return x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+}
+
+func clientCert(t *testing.T, ca testCA, template x509.Certificate) tls.Certificate {
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	certPEM, keyPEM := ca.issue(t, 100, template)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+type tlsFiles struct {
+	dir string
+	cfg config.TLS
+}
+
+func writeTLSFiles(t *testing.T, ca testCA, serial int64) tlsFiles {
+	f := tlsFiles{dir: t.TempDir()}
+	f.cfg = config.TLS{
+		CertFile:     filepath.Join(f.dir, "tls.crt"),
+		KeyFile:      filepath.Join(f.dir, "tls.key"),
+		ClientCAFile: filepath.Join(f.dir, "ca.crt"),
+	}
+	f.rotate(t, ca, serial, time.Now())
+	require.NoError(t, os.WriteFile(f.cfg.ClientCAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600))
+	return f
+}
+
+func (f tlsFiles) rotate(t *testing.T, ca testCA, serial int64, modTime time.Time) {
+	certPEM, keyPEM := ca.issue(t, serial, serverCert())
+	require.NoError(t, os.WriteFile(f.cfg.CertFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(f.cfg.KeyFile, keyPEM, 0o600))
+	require.NoError(t, os.Chtimes(f.cfg.CertFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(f.cfg.KeyFile, modTime, modTime))
+}
+
+func startTLSServer(t *testing.T, cfg config.TLS, h http.Handler) *httptest.Server {
+	tlsConfig, err := server.NewTLSConfig(cfg)
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(h)
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func tlsClient(ca testCA, certs ...tls.Certificate) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: ca.pool(), Certificates: certs},
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestClientCertAuthentication(t *testing.T) {
+	ca := newTestCA(t)
+	files := writeTLSFiles(t, ca, 2)
+
+	authn := server.Authentication{
+		Basic:      auth.NewStaticAuthenticator("user", "password", nil),
+		ClientCert: true,
+		Admins:     auth.NewAdmins([]string{"sensor-gateway"}),
+	}
+	srv := startTLSServer(t, files.cfg, server.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		w.Write([]byte(principal.Method + " " + principal.Name))
+	}), authn, nil))
+
+	tests := []struct {
+		name         string
+		client       *http.Client
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Common name",
+			client:       tlsClient(ca, clientCert(t, ca, x509.Certificate{Subject: pkix.Name{CommonName: "sensor-gateway"}})),
+			expectedCode: http.StatusOK,
+			expectedBody: "mtls sensor-gateway",
+		},
+		{
+			name:         "DNS SAN",
+			client:       tlsClient(ca, clientCert(t, ca, x509.Certificate{DNSNames: []string{"gw.example.com"}})),
+			expectedCode: http.StatusOK,
+			expectedBody: "mtls gw.example.com",
+		},
+		{
+			name:         "No certificate",
+			client:       tlsClient(ca),
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get(srv.URL + "/v1/devices")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedBody != "" {
+				body := make([]byte, 64)
+				n, _ := resp.Body.Read(body)
+				assert.Equal(t, tt.expectedBody, string(body[:n]))
+			}
+		})
+	}
+
+	// only certificates listed as admins may manage API keys
+	for name, expectedCode := range map[string]int{"sensor-gateway": http.StatusOK, "gw.example.com": http.StatusForbidden} {
+		resp, err := tlsClient(ca, clientCert(t, ca, x509.Certificate{Subject: pkix.Name{CommonName: name}})).Get(srv.URL + "/v1/admin/api-keys")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, expectedCode, resp.StatusCode, name)
+	}
+
+	otherCA := newTestCA(t)
+	_, err := tlsClient(ca, clientCert(t, otherCA, x509.Certificate{Subject: pkix.Name{CommonName: "intruder"}})).Get(srv.URL)
+	assert.Error(t, err, "certificates of unknown CAs are rejected")
+}
+
+func TestNewTLSConfig_RequireClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	files := writeTLSFiles(t, ca, 2)
+	files.cfg.RequireClientCert = true
+	files.cfg.MinVersion = "1.3"
+	srv := startTLSServer(t, files.cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	_, err := tlsClient(ca).Get(srv.URL)
+	assert.Error(t, err)
+
+	resp, err := tlsClient(ca, clientCert(t, ca, x509.Certificate{Subject: pkix.Name{CommonName: "gw"}})).Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	old := tlsClient(ca, clientCert(t, ca, x509.Certificate{Subject: pkix.Name{CommonName: "gw"}}))
+	old.Transport.(*http.Transport).TLSClientConfig.MaxVersion = tls.VersionTLS12
+	_, err = old.Get(srv.URL)
+	assert.Error(t, err, "TLS 1.2 is rejected")
+}
+
+func TestNewTLSConfig_ReloadsRotatedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	files := writeTLSFiles(t, ca, 2)
+	files.cfg.ReloadInterval = time.Nanosecond
+	srv := startTLSServer(t, files.cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serial := func() int64 {
+		resp, err := tlsClient(ca).Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	assert.Equal(t, int64(2), serial())
+
+	files.rotate(t, ca, 3, time.Now().Add(time.Minute))
+	assert.Equal(t, int64(3), serial())
+
+	// a broken certificate keeps the previous one in use
+	require.NoError(t, os.WriteFile(files.cfg.CertFile, []byte("garbage"), 0o600))
+	assert.Equal(t, int64(3), serial())
+}
+
+func TestNewTLSConfig_Invalid(t *testing.T) {
+	ca := newTestCA(t)
+	files := writeTLSFiles(t, ca, 2)
+
+	tests := []struct {
+		name string
+		cfg  config.TLS
+	}{
+		{name: "No key", cfg: config.TLS{CertFile: files.cfg.CertFile}},
+		{name: "Required client cert without CA", cfg: config.TLS{CertFile: files.cfg.CertFile, KeyFile: files.cfg.KeyFile, RequireClientCert: true}},
+		{name: "Unknown version", cfg: config.TLS{CertFile: files.cfg.CertFile, KeyFile: files.cfg.KeyFile, MinVersion: "1.1"}},
+		{name: "Missing file", cfg: config.TLS{CertFile: filepath.Join(files.dir, "missing.crt"), KeyFile: files.cfg.KeyFile}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.NewTLSConfig(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"homework/internal/reqinfo"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware records requests in server spans, continuing the trace of the W3C traceparent
+// header if the request has one. The span is named after the route set with reqinfo.SetRoute,
+// responses with 5xx statuses mark it as failed.
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethod(r.Method), semconv.URLPath(r.URL.Path)),
+		)
+		defer span.End()
+
+		w, r, info := reqinfo.Record(w, r.WithContext(ctx))
+		h.ServeHTTP(w, r)
+
+		if route := info.Route(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPStatusCode(info.Status()))
+		if info.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(info.Status()))
+		}
+	})
+}
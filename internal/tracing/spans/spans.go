@@ -0,0 +1,17 @@
+// Package spans holds span helpers shared by the tracing package and the packages it instruments,
+// it doesn't import them to avoid cycles.
+package spans
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// End records err on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
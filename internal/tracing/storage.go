@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"homework/internal/app"
+	"homework/internal/device"
+	"homework/internal/tracing/spans"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Storage records a span for every operation of the wrapped storage.
+type Storage struct {
+	next app.DeviceStorage
+}
+
+func NewStorage(next app.DeviceStorage) *Storage {
+	return &Storage{next: next}
+}
+
+func (s *Storage) GetDeviceBySerialNum(ctx context.Context, serialNum string) (device.Device, error) {
+	ctx, span := start(ctx, "GetDeviceBySerialNum", attribute.String("device.serial_num", serialNum))
+	d, err := s.next.GetDeviceBySerialNum(ctx, serialNum)
+	spans.End(span, err)
+	return d, err
+}
+
+func (s *Storage) CreateDevice(ctx context.Context, d device.Device) error {
+	ctx, span := start(ctx, "CreateDevice", attribute.String("device.serial_num", d.SerialNum))
+	err := s.next.CreateDevice(ctx, d)
+	spans.End(span, err)
+	return err
+}
+
+func (s *Storage) DeleteDeviceBySerialNum(ctx context.Context, serialNum string) error {
+	ctx, span := start(ctx, "DeleteDeviceBySerialNum", attribute.String("device.serial_num", serialNum))
+	err := s.next.DeleteDeviceBySerialNum(ctx, serialNum)
+	spans.End(span, err)
+	return err
+}
+
+func (s *Storage) UpdateDevice(ctx context.Context, d device.Device) error {
+	ctx, span := start(ctx, "UpdateDevice", attribute.String("device.serial_num", d.SerialNum))
+	err := s.next.UpdateDevice(ctx, d)
+	spans.End(span, err)
+	return err
+}
+
+func (s *Storage) ListDevices(ctx context.Context, query device.ListQuery) (device.Page, error) {
+	ctx, span := start(ctx, "ListDevices", attribute.Int("list.limit", query.Limit))
+	page, err := s.next.ListDevices(ctx, query)
+	if err == nil {
+		span.SetAttributes(attribute.Int("list.devices", len(page.Devices)))
+	}
+	spans.End(span, err)
+	return page, err
+}
+
+func (s *Storage) CountDevicesByModel(ctx context.Context) (map[string]int, error) {
+	ctx, span := start(ctx, "CountDevicesByModel")
+	counts, err := s.next.CountDevicesByModel(ctx)
+	spans.End(span, err)
+	return counts, err
+}
+
+// Close closes the wrapped storage if it holds resources.
+func (s *Storage) Close() error {
+	if closer, ok := s.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, "DeviceStorage."+operation, trace.WithAttributes(attrs...))
+}
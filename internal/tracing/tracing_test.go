@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"homework/internal/adapters/fakerepo"
+	"homework/internal/app"
+	"homework/internal/config"
+	"homework/internal/device"
+	"homework/internal/reqinfo"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// newRecorder installs a tracer provider recording ended spans for the duration of the test.
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	rec := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+func spanNamed(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span named %q", name)
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		traceparent string
+		route       string
+		status      int
+		wantName    string
+		wantCode    codes.Code
+	}{
+		{
+			name:        "continues trace",
+			path:        "/v1/devices/1234",
+			traceparent: traceparent,
+			route:       "/v1/devices/{id}",
+			status:      http.StatusNotFound,
+			wantName:    "GET /v1/devices/{id}",
+			wantCode:    codes.Unset,
+		},
+		{
+			name:     "starts trace",
+			path:     "/v1/devices",
+			route:    "/v1/devices",
+			status:   http.StatusServiceUnavailable,
+			wantName: "GET /v1/devices",
+			wantCode: codes.Error,
+		},
+		{
+			name:     "unmatched route",
+			path:     "/wp-login.php",
+			status:   http.StatusOK,
+			wantName: "GET",
+			wantCode: codes.Unset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newRecorder(t)
+			var handlerSpan trace.SpanContext
+			h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				if tt.route != "" {
+					reqinfo.SetRoute(r.Context(), tt.route)
+				}
+				w.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := rec.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tt.wantName, span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Equal(t, tt.wantCode, span.Status().Code)
+			assert.Equal(t, span.SpanContext(), handlerSpan, "handlers must get the server span")
+			if tt.traceparent != "" {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+				assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+				assert.True(t, span.Parent().IsRemote())
+			} else {
+				assert.False(t, span.Parent().IsValid())
+			}
+		})
+	}
+}
+
+type failingStorage struct {
+	app.DeviceStorage
+}
+
+func (failingStorage) UpdateDevice(context.Context, device.Device) error {
+	return app.Wrap(app.ErrUnavailable, "storage_unavailable", "failed to update device", errors.New("disk I/O error"))
+}
+
+func TestStorage(t *testing.T) {
+	rec := newRecorder(t)
+	storage := NewStorage(fakerepo.NewDeviceStorage())
+	d := device.Device{SerialNum: "1234", Model: "hp", IP: "1.1.1.1"}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, storage.CreateDevice(ctx, d))
+	_, err := storage.GetDeviceBySerialNum(ctx, "5678")
+	assert.ErrorIs(t, err, app.ErrNotFound)
+	parent.End()
+
+	create := spanNamed(t, rec.Ended(), "DeviceStorage.CreateDevice")
+	assert.Equal(t, parent.SpanContext().SpanID(), create.Parent().SpanID())
+	assert.Equal(t, codes.Unset, create.Status().Code)
+
+	get := spanNamed(t, rec.Ended(), "DeviceStorage.GetDeviceBySerialNum")
+	assert.Equal(t, codes.Error, get.Status().Code)
+	require.Len(t, get.Events(), 1, "the error must be recorded")
+}
+
+// TestUpdateDeviceTrace follows an update from the server span through the service to the storage.
+func TestUpdateDeviceTrace(t *testing.T) {
+	rec := newRecorder(t)
+	service := app.NewService(NewStorage(failingStorage{fakerepo.NewDeviceStorage()}))
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqinfo.SetRoute(r.Context(), "/v1/devices/{id}")
+		if err := service.UpdateDevice(r.Context(), device.Device{SerialNum: "1234"}); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	req := httptest.NewRequest("PUT", "/v1/devices/1234", nil)
+	req.Header.Set("traceparent", traceparent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	require.Len(t, spans, 3)
+	serverSpan := spanNamed(t, spans, "PUT /v1/devices/{id}")
+	serviceSpan := spanNamed(t, spans, "DeviceService.UpdateDevice")
+	storageSpan := spanNamed(t, spans, "DeviceStorage.UpdateDevice")
+
+	assert.Equal(t, serverSpan.SpanContext().SpanID(), serviceSpan.Parent().SpanID())
+	assert.Equal(t, serviceSpan.SpanContext().SpanID(), storageSpan.Parent().SpanID())
+	for _, span := range spans {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		assert.Equal(t, codes.Error, span.Status().Code, span.Name())
+	}
+}
+
+func TestSetup(t *testing.T) {
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), config.Tracing{Exporter: "file", FilePath: path, SampleRatio: 1, ServiceName: "deviced"})
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "exported span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"exported span"`)
+	assert.Contains(t, string(data), `"Value":"deviced"`)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), span.SpanContext()), propagation.HeaderCarrier(req.Header))
+	assert.NotEmpty(t, req.Header.Get("traceparent"), "W3C trace context must be propagated")
+}
+
+func TestSetup_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Tracing
+		wantErr error
+	}{
+		{name: "file without path", cfg: config.Tracing{Exporter: "file"}, wantErr: ErrFilePathRequired},
+		{name: "unknown exporter", cfg: config.Tracing{Exporter: "zipkin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Setup(context.Background(), tt.cfg)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	shutdown, err := Setup(context.Background(), config.Tracing{Exporter: "none"})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}